@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+)
+
+func NewCacheCmd(appConfig *app.AppConfig) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and clean the package cache",
+	}
+
+	cmd.AddCommand(NewCacheListCmd(appConfig))
+	cmd.AddCommand(NewCacheVerifyCmd(appConfig))
+	cmd.AddCommand(NewCachePruneCmd(appConfig))
+	cmd.AddCommand(NewCacheSizeCmd(appConfig))
+
+	return cmd
+}
+
+func NewCacheListCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List cached packages with their versions and sizes",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			packages, err := app.ListCachedPackages(appConfig.CacheDir)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case "table":
+				return app.WriteCacheTable(out, packages)
+			case "json":
+				if packages == nil {
+					packages = []*app.CachedPackage{}
+				}
+				data, err := json.MarshalIndent(packages, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(out, string(data))
+				return err
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "Output format <table, json>")
+
+	return cmd
+}
+
+func NewCacheVerifyCmd(appConfig *app.AppConfig) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Re-hash cached archives against the checksums of their metadata",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			packages, err := app.ListCachedPackages(appConfig.CacheDir)
+			if err != nil {
+				return err
+			}
+
+			var errs []error
+			for _, p := range packages {
+				if err := cmd.Context().Err(); err != nil {
+					return err
+				}
+				if err := p.Verify(); err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", p.PackageUri, err))
+				}
+			}
+
+			fmt.Fprintf(cmd.OutOrStdout(), "Verified %d packages, %d failed\n", len(packages), len(errs))
+
+			return errors.Join(errs...)
+		},
+	}
+
+	return cmd
+}
+
+func NewCachePruneCmd(appConfig *app.AppConfig) *cobra.Command {
+	var projects []string
+	var olderThan int
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete cached package versions which are not used anymore",
+		Long: `Delete cached package versions not referenced by PklProject.deps.json of the given projects,
+or not modified for the given number of days. When both are given, only versions matching both are deleted.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(projects) == 0 && olderThan <= 0 {
+				return errors.New("at least one of --project or --older-than is required")
+			}
+
+			referenced, err := app.ReferencedPackages(projects)
+			if err != nil {
+				return err
+			}
+
+			packages, err := app.ListCachedPackages(appConfig.CacheDir)
+			if err != nil {
+				return err
+			}
+
+			cutoff := time.Now().AddDate(0, 0, -olderThan)
+			out := cmd.OutOrStdout()
+
+			var pruned int64
+			var count int
+			for _, p := range packages {
+				if len(projects) > 0 && p.Referenced(referenced) {
+					continue
+				}
+				if olderThan > 0 && p.Modified.After(cutoff) {
+					continue
+				}
+
+				fmt.Fprintf(out, "Pruning %s (%s)\n", p.PackageUri, app.FormatSize(p.Size))
+
+				if !dryRun {
+					if err := p.Remove(cmd.Context(), appConfig.CacheDir); err != nil {
+						return err
+					}
+				}
+
+				pruned += p.Size
+				count++
+			}
+
+			fmt.Fprintf(out, "Pruned %d packages, %s\n", count, app.FormatSize(pruned))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringArrayVar(&projects, "project", nil, "Project directory whose PklProject.deps.json packages are kept, can be repeated")
+	cmd.Flags().IntVar(&olderThan, "older-than", 0, "Delete package versions not modified for the given number of days")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "Only print package versions which would be deleted")
+
+	return cmd
+}
+
+func NewCacheSizeCmd(appConfig *app.AppConfig) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "size",
+		Short: "Print the total size of the package cache",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			packages, err := app.ListCachedPackages(appConfig.CacheDir)
+			if err != nil {
+				return err
+			}
+
+			var size int64
+			for _, p := range packages {
+				size += p.Size
+			}
+
+			_, err = fmt.Fprintf(cmd.OutOrStdout(), "%d packages, %s\n", len(packages), app.FormatSize(size))
+			return err
+		},
+	}
+
+	return cmd
+}
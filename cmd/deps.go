@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+func NewDepsCmd(appConfig *app.AppConfig) *cobra.Command {
+
+	cmd := &cobra.Command{
+		Use:   "deps",
+		Short: "Inspect project dependencies",
+		Long:  "Inspect project dependencies.\n\n" + configHelp,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			appConfig.Logger.SetOut(cmd.ErrOrStderr())
+		},
+	}
+
+	cmd.PersistentFlags().BoolVarP(&appConfig.PlainHttp, "plain-http", "p", false, "Use plain http for registry")
+	cmd.PersistentFlags().BoolVar(&appConfig.Offline, "offline", false, "Resolve only from packages already in the cache, without network access")
+	cmd.PersistentFlags().IntVar(&appConfig.Concurrency, "concurrency", 8, "Maximum number of packages resolved in parallel")
+	cmd.PersistentFlags().StringVar(&appConfig.Strategy, "strategy", app.StrategyHighest, "Version selection strategy <highest, minimal>")
+
+	cmd.AddCommand(NewDepsTreeCmd(appConfig))
+	cmd.AddCommand(NewDepsWhyCmd(appConfig))
+	cmd.AddCommand(NewDepsOutdatedCmd(appConfig))
+	cmd.AddCommand(NewDepsUpdateCmd(appConfig))
+
+	return cmd
+}
+
+func NewDepsTreeCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "tree",
+		Short: "Print resolved dependency graph",
+		Long:  "Print resolved dependency graph.\n\n" + configHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graph, err := ResolveGraph(cmd.Context(), appConfig)
+			if err != nil {
+				return err
+			}
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case "text":
+				return app.WriteGraphText(out, graph)
+			case "json":
+				return app.WriteGraphJson(out, graph)
+			case "dot":
+				return app.WriteGraphDot(out, graph)
+			case "mermaid":
+				return app.WriteGraphMermaid(out, graph)
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "Output format <text, json, dot, mermaid>")
+
+	return cmd
+}
+
+func NewDepsWhyCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "why <package>",
+		Short: "Show dependency paths pulling in a package",
+		Long:  "Show every dependency path from the project to a package given by name, path or uri, e.g. k8s, pkl-k8s/k8s or pkl-k8s/k8s@1.0.1.\n\n" + configHelp,
+		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			graph, err := ResolveGraph(cmd.Context(), appConfig)
+			if err != nil {
+				return err
+			}
+
+			paths := app.GraphPaths(graph, args[0])
+
+			if len(paths) == 0 {
+				return fmt.Errorf("package %s is not a dependency of the project", args[0])
+			}
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case "text":
+				return app.WritePathsText(out, paths)
+			case "json":
+				data, err := json.MarshalIndent(pathsJson(paths), "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(out, string(data))
+				return err
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "text", "Output format <text, json>")
+
+	return cmd
+}
+
+func NewDepsOutdatedCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+	var all bool
+
+	cmd := &cobra.Command{
+		Use:   "outdated",
+		Short: "List dependencies with newer versions available",
+		Long:  "List dependencies with newer versions available.\n\n" + configHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := app.NewResolver(appConfig)
+			if err != nil {
+				return err
+			}
+
+			deduplicated, err := resolveDeduplicated(cmd.Context(), appConfig, resolver)
+			if err != nil {
+				return err
+			}
+
+			packages, err := resolver.Outdated(cmd.Context(), deduplicated, lockedVersions(appConfig))
+			if err != nil {
+				return err
+			}
+
+			if !all {
+				var outdated []*app.OutdatedPackage
+				for _, p := range packages {
+					if p.Outdated() {
+						outdated = append(outdated, p)
+					}
+				}
+				packages = outdated
+			}
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case "table":
+				return app.WriteOutdatedTable(out, packages)
+			case "json":
+				if packages == nil {
+					packages = []*app.OutdatedPackage{}
+				}
+				data, err := json.MarshalIndent(packages, "", "  ")
+				if err != nil {
+					return err
+				}
+				_, err = fmt.Fprintln(out, string(data))
+				return err
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "Output format <table, json>")
+	cmd.Flags().BoolVarP(&all, "all", "a", false, "Include dependencies which are up to date")
+
+	return cmd
+}
+
+// lockedVersions reads versions of remote dependencies from PklProject.deps.json, when it exists
+func lockedVersions(appConfig *app.AppConfig) map[string]string {
+	result := make(map[string]string)
+
+	deps, err := pklutils.PklReadDeps(appConfig.WorkingDir)
+	if err != nil {
+		return result
+	}
+
+	for key, dep := range deps.ResolvedDependencies {
+		if dep.DependencyType == "remote" {
+			_, version := pklutils.PklSplitVersion(dep.Uri)
+			result[key] = version
+		}
+	}
+
+	return result
+}
+
+type pathElement struct {
+	Name         string `json:"name"`
+	Uri          string `json:"uri"`
+	Requested    string `json:"requested"`
+	Resolved     string `json:"resolved"`
+	Source       string `json:"source"`
+	MinorUpgrade bool   `json:"minorUpgrade"`
+}
+
+func pathsJson(paths [][]*app.GraphNode) [][]pathElement {
+	result := make([][]pathElement, len(paths))
+	for i, path := range paths {
+		for _, n := range path[1:] {
+			result[i] = append(result[i], pathElement{
+				Name:         n.Name,
+				Uri:          n.Uri,
+				Requested:    n.Requested,
+				Resolved:     n.Resolved,
+				Source:       n.Source,
+				MinorUpgrade: n.MinorUpgrade(),
+			})
+		}
+	}
+	return result
+}
+
+func resolveDeduplicated(ctx context.Context, appConfig *app.AppConfig, resolver *app.Resolver) (map[string]*app.Metadata, error) {
+	project := appConfig.Project()
+
+	resolvedDependencies, err := resolver.Resolve(ctx, CollectRemoteDependencies(project.Dependencies()))
+	if err != nil {
+		return nil, err
+	}
+
+	return resolver.Deduplicate(resolvedDependencies)
+}
+
+// ResolveGraph resolves the project dependencies and builds the dependency tree
+func ResolveGraph(ctx context.Context, appConfig *app.AppConfig) (*app.GraphNode, error) {
+	resolver, err := app.NewResolver(appConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	project := appConfig.Project()
+
+	deduplicated, err := resolveDeduplicated(ctx, appConfig, resolver)
+	if err != nil {
+		return nil, err
+	}
+
+	name := "root"
+	if project.Package != nil {
+		name = project.Package.Name
+	}
+
+	return resolver.Graph(ctx, name, project.Dependencies(), deduplicated)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+)
+
+func NewLicensesCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+	var check bool
+
+	cmd := &cobra.Command{
+		Use:   "licenses",
+		Short: "List licenses of the resolved project dependencies",
+		Long:  "List licenses of the resolved project dependencies.\n\n" + configHelp,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			appConfig.Logger.SetOut(cmd.ErrOrStderr())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := app.NewResolver(appConfig)
+			if err != nil {
+				return err
+			}
+
+			deduplicated, err := resolveDeduplicated(cmd.Context(), appConfig, resolver)
+			if err != nil {
+				return err
+			}
+
+			config, err := appConfig.Config()
+			if err != nil {
+				return err
+			}
+
+			licenses := app.PackageLicenses(deduplicated, config.Licenses)
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case "table":
+				err = app.WriteLicenseTable(out, licenses)
+			case "json":
+				var data []byte
+				data, err = json.MarshalIndent(licenses, "", "  ")
+				if err == nil {
+					_, err = fmt.Fprintln(out, string(data))
+				}
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+
+			if err != nil || !check {
+				return err
+			}
+
+			for _, l := range licenses {
+				if l.Violation != "" {
+					return errors.New("dependencies violate the license policy")
+				}
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", "table", "Output format <table, json>")
+	cmd.Flags().BoolVar(&check, "check", false, "Exit with an error when a dependency violates the license policy")
+	cmd.Flags().BoolVarP(&appConfig.PlainHttp, "plain-http", "p", false, "Use plain http for registry")
+	cmd.Flags().BoolVar(&appConfig.Offline, "offline", false, "Resolve only from packages already in the cache, without network access")
+	cmd.Flags().IntVar(&appConfig.Concurrency, "concurrency", 8, "Maximum number of packages resolved in parallel")
+	cmd.Flags().StringVar(&appConfig.Strategy, "strategy", app.StrategyHighest, "Version selection strategy <highest, minimal>")
+
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"hpkl.io/hpkl/pkg/app"
+)
+
+func TestLicensesJson(t *testing.T) {
+	if _, err := exec.LookPath("pkl"); err != nil {
+		t.Skip("pkl is required to evaluate PklProject")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+
+	workingDir := t.TempDir()
+	project := "amends \"pkl:Project\"\n\ndependencies {\n  [\"a\"] { uri = \"package://host/a@1.0.0\" }\n}\n"
+	if err := os.WriteFile(filepath.Join(workingDir, "PklProject"), []byte(project), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cacheDir := t.TempDir()
+	basePath := filepath.Join(cacheDir, "package-2", "host", "a@1.0.0")
+	metadata := `{"name": "a", "version": "1.0.0", "packageUri": "package://host/a@1.0.0", "license": "MIT"}`
+	if err := os.MkdirAll(basePath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(basePath, "a@1.0.0.json"), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+
+	appConfig, err := app.NewAppConfig(context.Background(), stdout, stderr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	appConfig.WorkingDir = workingDir
+	appConfig.CacheDir = cacheDir
+
+	cmd := NewLicensesCmd(appConfig)
+	cmd.SetOut(stdout)
+	cmd.SetErr(stderr)
+	cmd.SetArgs([]string{"-f", "json", "--offline"})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("%v: %s", err, stderr.String())
+	}
+
+	var licenses []*app.PackageLicense
+	if err := json.Unmarshal(stdout.Bytes(), &licenses); err != nil {
+		t.Fatalf("expected json on stdout, got %q: %v", stdout.String(), err)
+	}
+
+	if len(licenses) != 1 || licenses[0].License != "MIT" {
+		t.Errorf("unexpected licenses %+v", licenses)
+	}
+
+	if stderr.Len() == 0 {
+		t.Error("expected progress on stderr")
+	}
+}
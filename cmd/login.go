@@ -19,11 +19,22 @@ func NewLoginCmd(appConfig *app.AppConfig) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "login",
 		Short: "Login to the registry",
+		Long:  "Login to the registry.\n\n" + configHelp,
 		Args:  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
 		RunE: func(cmd *cobra.Command, args []string) error {
 
-			client, err := registry.NewClient()
+			httpClient, err := appConfig.HttpClient()
+			if err != nil {
+				return err
+			}
+
+			client, err := registry.NewClient(registry.ClientOptHTTPClient(httpClient))
+
+			if err != nil {
+				return err
+			}
 
+			transport, err := appConfig.TransportOptions(args[0])
 			if err != nil {
 				return err
 			}
@@ -39,9 +50,11 @@ func NewLoginCmd(appConfig *app.AppConfig) *cobra.Command {
 			}
 
 			err = client.Login(
+				cmd.Context(),
 				args[0],
 				registry.LoginOptBasicAuth(Login, Password),
-				registry.LoginOptInsecure(Insecure),
+				registry.LoginOptInsecure(Insecure || transport.Insecure),
+				registry.LoginOptTLSClientConfig(transport.CertFile, transport.KeyFile, transport.CaFile),
 			)
 			if err != nil {
 				return err
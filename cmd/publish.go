@@ -17,6 +17,7 @@ func NewPublishCmd(appConfig *app.AppConfig) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "publish",
 		Short: "publish package to oci registry",
+		Long:  "Publish package to oci registry.\n\n" + configHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
 
 			project := appConfig.Project()
@@ -24,7 +25,15 @@ func NewPublishCmd(appConfig *app.AppConfig) *cobra.Command {
 			version := project.Package.Version
 			baseUri := project.Package.BaseUri
 
-			client, err := registry.NewClient(registry.WithPlainHttp(appConfig.PlainHttp))
+			httpClient, err := appConfig.HttpClient()
+			if err != nil {
+				return err
+			}
+
+			client, err := registry.NewClient(
+				registry.WithPlainHttp(appConfig.PlainHttp),
+				registry.ClientOptHTTPClient(httpClient),
+			)
 			if err != nil {
 				return err
 			}
@@ -40,7 +49,7 @@ func NewPublishCmd(appConfig *app.AppConfig) *cobra.Command {
 				return err
 			}
 
-			pushResult, err := client.Push(archivePath, metadataPath, ref, appConfig.Project())
+			pushResult, err := client.Push(cmd.Context(), archivePath, metadataPath, ref, appConfig.Project())
 
 			if err != nil {
 				return err
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"maps"
 	"net/url"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/apple/pkl-go/pkl"
 	"github.com/spf13/cobra"
@@ -16,24 +20,41 @@ func NewResolveCmd(appConfig *app.AppConfig) *cobra.Command {
 
 	logger := appConfig.Logger
 
+	var frozen bool
+	var workspace bool
+
+	resolve := func(ctx context.Context) error {
+		if frozen {
+			return CheckResolved(ctx, appConfig)
+		}
+		return Resolve(ctx, appConfig)
+	}
+
 	cmd := &cobra.Command{
 		Use:   "resolve",
 		Short: "Resolve all dependencies from pkl project",
+		Long:  "Resolve all dependencies from pkl project.\n\n" + configHelp,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if workspace {
+				if len(args) > 0 {
+					return errors.New("--workspace resolves the members of PklWorkspace, use --working-dir instead of directory arguments")
+				}
+				return ResolveWorkspace(cmd.Context(), appConfig, frozen)
+			}
 			if len(args) > 0 {
 				for _, v := range args {
 					logger.Info("Resolving path: %s", v)
 					appConfig.WorkingDir = v
 					appConfig.Reset()
-					err := Resolve(appConfig)
+					err := resolve(cmd.Context())
 					if err != nil {
-						panic(err)
+						return err
 					}
 				}
 			} else {
-				err := Resolve(appConfig)
+				err := resolve(cmd.Context())
 				if err != nil {
-					panic(err)
+					return err
 				}
 			}
 			return nil
@@ -41,6 +62,13 @@ func NewResolveCmd(appConfig *app.AppConfig) *cobra.Command {
 	}
 
 	cmd.Flags().BoolVarP(&appConfig.PlainHttp, "plain-http", "p", false, "Use plain http for registry")
+	cmd.Flags().BoolVar(&frozen, "frozen", false, "Verify that PklProject.deps.json is up to date instead of rewriting it")
+	cmd.Flags().BoolVar(&frozen, "check", false, "Alias for --frozen")
+	cmd.Flags().BoolVar(&workspace, "workspace", false, "Resolve all members of PklWorkspace in the working directory as one dependency graph")
+	cmd.Flags().BoolVar(&appConfig.Offline, "offline", false, "Resolve only from packages already in the cache, without network access")
+	cmd.Flags().IntVar(&appConfig.Concurrency, "concurrency", 8, "Maximum number of packages resolved and downloaded in parallel")
+	cmd.Flags().StringVar(&appConfig.Strategy, "strategy", app.StrategyHighest, "Version selection strategy <highest, minimal>")
+	cmd.Flags().BoolVar(&appConfig.VerifyCache, "verify-cache", false, "Re-check checksums of already cached package archives")
 
 	return cmd
 }
@@ -83,37 +111,99 @@ func CollectRemoteDependencies(dependecies *pkl.ProjectDependencies) map[string]
 	return result
 }
 
-func Resolve(appConfig *app.AppConfig) error {
+func Resolve(ctx context.Context, appConfig *app.AppConfig) error {
+	projectDeps, err := ResolveProjectDeps(ctx, appConfig, true)
+	if err != nil {
+		return err
+	}
+
+	err = pklutils.PklWriteDeps(appConfig.WorkingDir, projectDeps)
+	if err != nil {
+		appConfig.Logger.Error("Error on write deps")
+		return err
+	}
+
+	return nil
+}
+
+// CheckResolved resolves the project and compares the result with the existing PklProject.deps.json
+// without downloading packages or writing the file
+func CheckResolved(ctx context.Context, appConfig *app.AppConfig) error {
+	projectDeps, err := ResolveProjectDeps(ctx, appConfig, false)
+	if err != nil {
+		return err
+	}
+
+	existing, err := pklutils.PklReadDeps(appConfig.WorkingDir)
+	if err != nil {
+		appConfig.Logger.Error("Error on read deps")
+		return err
+	}
+
+	diff := pklutils.DiffDeps(existing, projectDeps)
+
+	if len(diff) > 0 {
+		return fmt.Errorf("PklProject.deps.json in %s is out of date:\n%s", appConfig.WorkingDir, strings.Join(diff, "\n"))
+	}
+
+	return nil
+}
+
+// ResolveProjectDeps resolves local and remote project dependencies into the PklProject.deps.json structure
+func ResolveProjectDeps(ctx context.Context, appConfig *app.AppConfig, download bool) (*pklutils.ProjectDeps, error) {
 	resolver, err := app.NewResolver(appConfig)
 	if err != nil {
 		appConfig.Logger.Error("Error on creating resolver")
-		return err
+		return nil, err
 	}
 
+	projectDeps, _, err := resolveProjectDeps(ctx, appConfig, resolver, download)
+
+	return projectDeps, err
+}
+
+// resolveProjectDeps also returns the deduplicated remote packages the PklProject.deps.json entries were built from
+func resolveProjectDeps(ctx context.Context, appConfig *app.AppConfig, resolver *app.Resolver, download bool) (*pklutils.ProjectDeps, map[string]*app.Metadata, error) {
 	project := appConfig.Project()
 
 	remoteDependencies := CollectRemoteDependencies(project.Dependencies())
 
-	resolvedDependencies, err := resolver.Resolve(remoteDependencies)
+	resolvedDependencies, err := resolver.Resolve(ctx, remoteDependencies)
 
 	if err != nil {
 		appConfig.Logger.Error("Error on resolving remote dependencies")
-		return err
+		return nil, nil, err
 	}
 
 	resolvedDependencies, err = resolver.Deduplicate(resolvedDependencies)
 
 	if err != nil {
 		appConfig.Logger.Error("Error on deduplication")
-		return err
+		return nil, nil, err
 	}
 
-	err = resolver.Download(resolvedDependencies)
+	if err := resolver.CheckLicenses(resolvedDependencies); err != nil {
+		appConfig.Logger.Error("Error on license check")
+		return nil, nil, err
+	}
 
-	if err != nil {
-		return err
+	if download {
+		err = resolver.Download(ctx, resolvedDependencies)
+
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
+	projectDeps, err := buildProjectDeps(appConfig, resolver, resolvedDependencies)
+
+	return projectDeps, resolvedDependencies, err
+}
+
+// buildProjectDeps creates PklProject.deps.json entries of the given remote packages and local dependencies of the project
+func buildProjectDeps(appConfig *app.AppConfig, resolver *app.Resolver, resolvedDependencies map[string]*app.Metadata) (*pklutils.ProjectDeps, error) {
+	project := appConfig.Project()
+
 	dependencies := make(map[string]*pklutils.ResolvedDependency, len(resolvedDependencies)+len(project.Dependencies().LocalDependencies))
 
 	projectDeps := pklutils.ProjectDeps{
@@ -127,13 +217,13 @@ func Resolve(appConfig *app.AppConfig) error {
 
 		if err != nil {
 			appConfig.Logger.Error("Error on dependency resolving")
-			return err
+			return nil, err
 		}
 
 		packageUri, err := url.Parse(dep.PackageUri)
 
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		packageUri.Scheme = "projectpackage"
@@ -150,7 +240,7 @@ func Resolve(appConfig *app.AppConfig) error {
 	projectFileUri, err := url.Parse(project.ProjectFileUri)
 	if err != nil {
 		appConfig.Logger.Error("Error on Url Parsing")
-		return err
+		return nil, err
 	}
 
 	projectFilePath := filepath.Dir(projectFileUri.Path)
@@ -164,7 +254,7 @@ func Resolve(appConfig *app.AppConfig) error {
 		projectUri, err := url.Parse(dep.Uri)
 		if err != nil {
 			appConfig.Logger.Error("Error on Url Parsing in dependency")
-			return err
+			return nil, err
 		}
 		projectUri.Scheme = "projectpackage"
 
@@ -172,14 +262,14 @@ func Resolve(appConfig *app.AppConfig) error {
 
 		depProjectFileUri, err := url.Parse(dep.ProjectFileUri)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		rel, err := filepath.Rel(projectFilePath, filepath.Dir(depProjectFileUri.Path))
 
 		if err != nil {
 			appConfig.Logger.Error("Error on Url Parsing in dependency")
-			return err
+			return nil, err
 		}
 
 		resolvedDependency := pklutils.ResolvedDependency{
@@ -190,11 +280,5 @@ func Resolve(appConfig *app.AppConfig) error {
 		projectDeps.ResolvedDependencies[mapUri] = &resolvedDependency
 	}
 
-	err = pklutils.PklWriteDeps(appConfig.WorkingDir, &projectDeps)
-	if err != nil {
-		appConfig.Logger.Error("Error on write deps")
-		return err
-	}
-
-	return nil
+	return &projectDeps, nil
 }
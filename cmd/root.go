@@ -6,7 +6,10 @@ package cmd
 import (
 	"context"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"syscall"
+	"time"
 
 	"log"
 
@@ -15,6 +18,11 @@ import (
 	"hpkl.io/hpkl/pkg/app"
 )
 
+// configHelp tells commands reading hpkl configuration where it is looked up
+const configHelp = `Sources, rewrites, http settings and credentials, license and admission policies are read from
+.hpkl/config.pkl in the working directory or, when it does not exist, from ~/.hpkl/config.pkl.
+--policy replaces the admission policy of the configuration.`
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:     "hpkl",
@@ -29,7 +37,10 @@ var rootCmd = &cobra.Command{
 }
 
 func Execute() {
-	err := rootCmd.Execute()
+	// Ctrl-C cancels the command context, so network calls are aborted and nothing partial is written to the cache
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
 		os.Exit(1)
 	}
@@ -46,6 +57,16 @@ func init() {
 		log.Fatal("Error starting app: ", err)
 	}
 
+	cobra.EnableTraverseRunHooks = true
+
+	rootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if appConfig.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), appConfig.Timeout)
+			cmd.SetContext(ctx)
+			cobra.OnFinalize(cancel)
+		}
+	}
+
 	rootCmd.AddCommand(NewLoginCmd(appConfig))
 	rootCmd.AddCommand(NewResolveCmd(appConfig))
 	rootCmd.AddCommand(NewPublishCmd(appConfig))
@@ -53,6 +74,11 @@ func init() {
 	rootCmd.AddCommand(NewEvalCmd(appConfig))
 	rootCmd.AddCommand(NewProjectCmd(appConfig))
 	rootCmd.AddCommand(NewDownloadPackageCmd(appConfig))
+	rootCmd.AddCommand(NewDepsCmd(appConfig))
+	rootCmd.AddCommand(NewCacheCmd(appConfig))
+	rootCmd.AddCommand(NewVendorCmd(appConfig))
+	rootCmd.AddCommand(NewSbomCmd(appConfig))
+	rootCmd.AddCommand(NewLicensesCmd(appConfig))
 	rootCmd.AddCommand(extension.NewVersionCobraCmd())
 
 	homeDir, err := os.UserHomeDir()
@@ -69,5 +95,17 @@ func init() {
 
 	rootCmd.PersistentFlags().StringVar(&appConfig.CacheDir, "cache-dir", filepath.Join(homeDir, ".pkl/cache"), "The cache directory for storing packages")
 	rootCmd.PersistentFlags().StringVarP(&appConfig.WorkingDir, "working-dir", "w", workingDir, "Base path that relative module paths are resolved against.")
+	rootCmd.PersistentFlags().DurationVar(&appConfig.Timeout, "timeout", 0, "Overall timeout of the command, e.g. 10m (0 disables it)")
+	rootCmd.PersistentFlags().DurationVar(&appConfig.RequestTimeout, "request-timeout", 5*time.Minute, "Timeout of a single registry or http request (0 disables it)")
+	rootCmd.PersistentFlags().IntVar(&appConfig.Retries, "retries", 3, "Number of retries of transient registry and http failures")
+	rootCmd.PersistentFlags().DurationVar(&appConfig.RetryMaxWait, "retry-max-wait", 30*time.Second, "Maximum wait between retries")
+	rootCmd.PersistentFlags().DurationVar(&appConfig.MetadataTtl, "metadata-ttl", 24*time.Hour, "How long cached metadata of mutable packages and version listings is used before revalidation")
+	rootCmd.PersistentFlags().BoolVar(&appConfig.NoMetadataCache, "no-metadata-cache", false, "Do not read or write the persistent package metadata cache")
+	rootCmd.PersistentFlags().StringVar(&appConfig.PolicyFile, "policy", "", "Admission policy file, replaces the policy of the hpkl configuration")
+	rootCmd.PersistentFlags().StringVar(&appConfig.Transport.CaFile, "ca-file", "", "CA bundle trusted in addition to system certificates for registry and https connections")
+	rootCmd.PersistentFlags().StringVar(&appConfig.Transport.CertFile, "cert-file", "", "Client certificate for registry and https connections")
+	rootCmd.PersistentFlags().StringVar(&appConfig.Transport.KeyFile, "key-file", "", "Key of the client certificate, if not included in --cert-file")
+	rootCmd.PersistentFlags().StringVar(&appConfig.Transport.Proxy, "proxy", "", "Proxy url overriding HTTP(S)_PROXY, \"direct\" disables proxies")
+	rootCmd.PersistentFlags().BoolVar(&appConfig.Transport.Insecure, "insecure-skip-verify", false, "Do not verify TLS certificates of registry and https connections")
 	rootCmd.PersistentFlags().StringVar(&appConfig.RootDir, "root-dir", "", "Restricts access to file-based modules and resources to those located under the root directory.")
 }
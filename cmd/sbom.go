@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+func NewSbomCmd(appConfig *app.AppConfig) *cobra.Command {
+	var format string
+
+	cmd := &cobra.Command{
+		Use:   "sbom",
+		Short: "Print software bill of materials of the resolved project dependencies",
+		Long:  "Print software bill of materials of the resolved project dependencies.\n\n" + configHelp,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			appConfig.Logger.SetOut(cmd.ErrOrStderr())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := app.NewResolver(appConfig)
+			if err != nil {
+				return err
+			}
+
+			deduplicated, err := resolveDeduplicated(cmd.Context(), appConfig, resolver)
+			if err != nil {
+				return err
+			}
+
+			components, err := resolver.SbomComponents(deduplicated)
+			if err != nil {
+				return err
+			}
+
+			project := appConfig.Project()
+
+			name := filepath.Base(appConfig.WorkingDir)
+			version := ""
+			license := ""
+			uri := project.ProjectFileUri
+			if project.Package != nil {
+				name = project.Package.Name
+				version = project.Package.Version
+				license = project.Package.License
+				uri = fmt.Sprintf("%s@%s", project.Package.BaseUri, version)
+			}
+
+			root, err := resolver.SbomRoot(name, version, uri, CollectRemoteDependencies(project.Dependencies()), deduplicated)
+			if err != nil {
+				return err
+			}
+			root.License = license
+
+			local, err := localComponents(appConfig)
+			if err != nil {
+				return err
+			}
+
+			for _, c := range local {
+				root.Dependencies = append(root.Dependencies, c.PackageUri)
+			}
+			components = append(components, local...)
+
+			out := cmd.OutOrStdout()
+
+			switch format {
+			case app.SbomCycloneDx:
+				return app.WriteCycloneDx(out, root, components)
+			case app.SbomSpdx:
+				return app.WriteSpdx(out, root, components)
+			default:
+				return fmt.Errorf("unknown format %s", format)
+			}
+		},
+	}
+
+	cmd.Flags().StringVarP(&format, "format", "f", app.SbomCycloneDx, "Output format <cyclonedx, spdx>")
+	cmd.Flags().BoolVarP(&appConfig.PlainHttp, "plain-http", "p", false, "Use plain http for registry")
+	cmd.Flags().BoolVar(&appConfig.Offline, "offline", false, "Resolve only from packages already in the cache, without network access")
+	cmd.Flags().IntVar(&appConfig.Concurrency, "concurrency", 8, "Maximum number of packages resolved in parallel")
+	cmd.Flags().StringVar(&appConfig.Strategy, "strategy", app.StrategyHighest, "Version selection strategy <highest, minimal>")
+
+	return cmd
+}
+
+// localComponents describes local project dependencies, their source is the path relative to the project
+func localComponents(appConfig *app.AppConfig) ([]*app.SbomComponent, error) {
+	var result []*app.SbomComponent
+
+	for _, dep := range CollectLocalDependencies(appConfig.Project().Dependencies()) {
+		_, version := pklutils.PklSplitVersion(dep.Uri)
+
+		projectFileUri, err := url.Parse(dep.ProjectFileUri)
+		if err != nil {
+			return nil, err
+		}
+
+		path, err := filepath.Rel(appConfig.WorkingDir, filepath.Dir(projectFileUri.Path))
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, &app.SbomComponent{
+			Name:       dep.Name,
+			Version:    version,
+			PackageUri: dep.Uri,
+			Source:     path,
+			Local:      true,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PackageUri < result[j].PackageUri
+	})
+
+	return result, nil
+}
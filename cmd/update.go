@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+func NewDepsUpdateCmd(appConfig *app.AppConfig) *cobra.Command {
+	var patch bool
+	var minor bool
+	var major bool
+
+	logger := appConfig.Logger
+
+	cmd := &cobra.Command{
+		Use:   "update [name...]",
+		Short: "Update dependency versions in PklProject and resolve the project",
+		Long:  "Update dependency versions in PklProject and resolve the project.\n\n" + configHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			resolver, err := app.NewResolver(appConfig)
+			if err != nil {
+				return err
+			}
+
+			project := appConfig.Project()
+
+			projectFile := filepath.Join(appConfig.WorkingDir, "PklProject")
+			if projectFileUri, err := url.Parse(project.ProjectFileUri); err == nil && projectFileUri.Path != "" {
+				projectFile = projectFileUri.Path
+			}
+
+			info, err := os.Stat(projectFile)
+			if err != nil {
+				return err
+			}
+
+			data, err := os.ReadFile(projectFile)
+			if err != nil {
+				return err
+			}
+
+			source := string(data)
+			remote := project.Dependencies().RemoteDependencies
+
+			names := args
+			if len(names) == 0 {
+				for name := range remote {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+			}
+
+			updated := false
+
+			for _, name := range names {
+				dep, ok := remote[name]
+				if !ok {
+					return fmt.Errorf("%s is not a remote dependency of the project", name)
+				}
+
+				baseUri, version := pklutils.PklSplitVersion(dep.PackageUri)
+
+				if app.IsVersionConstraint(version) {
+					logger.Info("Skipping %s: version constraint %s is resolved on every resolve", name, version)
+					continue
+				}
+
+				versions, err := resolver.DependencyVersions(cmd.Context(), app.Dependency{Name: name, Uri: dep.PackageUri})
+				if err != nil {
+					return err
+				}
+
+				patchVersion, minorVersion, majorVersion, err := app.UpgradeCandidates(versions, version)
+				if err != nil {
+					return err
+				}
+
+				target := minorVersion
+				switch {
+				case patch:
+					target = patchVersion
+				case major:
+					target = majorVersion
+				}
+
+				if target == version {
+					continue
+				}
+
+				newUri := fmt.Sprintf("%s@%s", baseUri, target)
+
+				var found bool
+				source, found = pklutils.PklRewriteDependencyUri(source, dep.PackageUri, newUri)
+
+				if !found {
+					logger.Error("Unable to find uri %s of %s in %s, update it manually to %s", dep.PackageUri, name, projectFile, target)
+					continue
+				}
+
+				logger.Info("Updating %s: %s -> %s", name, version, target)
+				updated = true
+			}
+
+			if !updated {
+				logger.Info("All dependencies are up to date")
+				return nil
+			}
+
+			if err := os.WriteFile(projectFile, []byte(source), info.Mode().Perm()); err != nil {
+				return err
+			}
+
+			appConfig.Reset()
+
+			// the project is evaluated before resolving, Project exits on errors which would leave the rewritten file
+			_, err = appConfig.ProjectOrErr()
+			if err == nil {
+				err = Resolve(cmd.Context(), appConfig)
+			}
+
+			if err != nil {
+				logger.Error("Restoring %s, the updated versions could not be resolved", projectFile)
+
+				appConfig.Reset()
+
+				return errors.Join(err, os.WriteFile(projectFile, data, info.Mode().Perm()))
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&patch, "patch", false, "Update to the latest patch version")
+	cmd.Flags().BoolVar(&minor, "minor", false, "Update to the latest minor version (default)")
+	cmd.Flags().BoolVar(&major, "major", false, "Update to the latest major version")
+	cmd.MarkFlagsMutuallyExclusive("patch", "minor", "major")
+
+	return cmd
+}
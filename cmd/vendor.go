@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+	"hpkl.io/hpkl/pkg/app"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+func NewVendorCmd(appConfig *app.AppConfig) *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   "vendor",
+		Short: "Copy all remote dependencies into the project and lock them as local dependencies",
+		Long:  "Copy all remote dependencies into the project and lock them as local dependencies.\n\n" + configHelp,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return Vendor(cmd.Context(), appConfig, dir)
+		},
+	}
+
+	cmd.Flags().StringVar(&dir, "dir", "vendor", "Directory inside the project the packages are extracted to")
+	cmd.Flags().BoolVarP(&appConfig.PlainHttp, "plain-http", "p", false, "Use plain http for registry")
+	cmd.Flags().BoolVar(&appConfig.Offline, "offline", false, "Resolve only from packages already in the cache, without network access")
+	cmd.Flags().IntVar(&appConfig.Concurrency, "concurrency", 8, "Maximum number of packages resolved and downloaded in parallel")
+	cmd.Flags().StringVar(&appConfig.Strategy, "strategy", app.StrategyHighest, "Version selection strategy <highest, minimal>")
+
+	return cmd
+}
+
+// Vendor resolves the project, extracts remote packages into dir and writes PklProject.deps.json
+// with local entries pointing to them
+func Vendor(ctx context.Context, appConfig *app.AppConfig, dir string) error {
+	vendorDir, err := app.VendorDir(appConfig.WorkingDir, dir)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := app.NewResolver(appConfig)
+	if err != nil {
+		return err
+	}
+
+	projectDeps, dependencies, err := resolveProjectDeps(ctx, appConfig, resolver, true)
+	if err != nil {
+		return err
+	}
+
+	if err := app.ResetVendorDir(vendorDir); err != nil {
+		return err
+	}
+
+	vendored, err := resolver.Vendor(dependencies, vendorDir)
+	if err != nil {
+		return err
+	}
+
+	for _, dep := range dependencies {
+		mapUri, err := resolver.MajorVersionPackage(dep)
+		if err != nil {
+			return err
+		}
+
+		packageUri, err := url.Parse(dep.PackageUri)
+		if err != nil {
+			return err
+		}
+		packageUri.Scheme = "projectpackage"
+
+		rel, err := filepath.Rel(appConfig.WorkingDir, vendored[dep.PackageUri])
+		if err != nil {
+			return err
+		}
+
+		projectDeps.ResolvedDependencies[mapUri] = &pklutils.ResolvedDependency{
+			DependencyType: "local",
+			Path:           rel,
+			Uri:            packageUri.String(),
+		}
+	}
+
+	return pklutils.PklWriteDeps(appConfig.WorkingDir, projectDeps)
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+
+	"hpkl.io/hpkl/pkg/app"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+// ResolveWorkspace resolves all members of the PklWorkspace in the working directory as one graph,
+// so every member gets the same version of a shared package, and writes PklProject.deps.json of each member
+// with the packages reachable from it
+func ResolveWorkspace(ctx context.Context, appConfig *app.AppConfig, frozen bool) error {
+	root := appConfig.WorkingDir
+	defer func() {
+		appConfig.WorkingDir = root
+		appConfig.Reset()
+	}()
+
+	workspace, err := app.LoadWorkspace(ctx, root)
+	if err != nil {
+		return err
+	}
+
+	resolver, err := app.NewResolver(appConfig)
+	if err != nil {
+		return err
+	}
+
+	members := make(map[string]map[string]app.Dependency, len(workspace.Members))
+	all := make(map[string]app.Dependency)
+
+	for _, member := range workspace.Members {
+		appConfig.WorkingDir = member
+		appConfig.Reset()
+
+		project, err := appConfig.ProjectOrErr()
+		if err != nil {
+			return err
+		}
+
+		members[member] = CollectRemoteDependencies(project.Dependencies())
+		maps.Copy(all, members[member])
+	}
+
+	resolved, err := resolver.Resolve(ctx, all)
+	if err != nil {
+		return err
+	}
+
+	deduplicated, err := resolver.Deduplicate(resolved)
+	if err != nil {
+		return err
+	}
+
+	if err := resolver.CheckLicenses(deduplicated); err != nil {
+		return err
+	}
+
+	if !frozen {
+		if err := resolver.Download(ctx, deduplicated); err != nil {
+			return err
+		}
+	}
+
+	var outdated []string
+
+	for _, member := range workspace.Members {
+		appConfig.WorkingDir = member
+		appConfig.Reset()
+
+		reachable, err := resolver.Reachable(members[member], deduplicated)
+		if err != nil {
+			return fmt.Errorf("%s: %w", member, err)
+		}
+
+		projectDeps, err := buildProjectDeps(appConfig, resolver, reachable)
+		if err != nil {
+			return err
+		}
+
+		if !frozen {
+			appConfig.Logger.Info("Writing %s/PklProject.deps.json", member)
+			if err := pklutils.PklWriteDeps(member, projectDeps); err != nil {
+				return err
+			}
+			continue
+		}
+
+		existing, err := pklutils.PklReadDeps(member)
+		if err != nil {
+			return err
+		}
+
+		if diff := pklutils.DiffDeps(existing, projectDeps); len(diff) > 0 {
+			outdated = append(outdated, fmt.Sprintf("PklProject.deps.json in %s is out of date:\n%s", member, strings.Join(diff, "\n")))
+		}
+	}
+
+	if len(outdated) > 0 {
+		return fmt.Errorf("%s", strings.Join(outdated, "\n"))
+	}
+
+	return nil
+}
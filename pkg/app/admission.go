@@ -0,0 +1,303 @@
+package app
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	// AdmissionPolicy restricts which packages may be resolved, configured as `policy` in .hpkl/config.pkl
+	// or read from the file given by --policy. Host and package patterns may contain * wildcards.
+	AdmissionPolicy struct {
+		// AllowedHosts are hosts of package uris (pkg.pkl-lang.org, *.internal), empty allows any host
+		AllowedHosts []string `json:"allowedHosts"`
+		// AllowedRegistries are hosts metadata and archives are fetched from after mirror rewrites,
+		// including hosts of package zip urls, empty allows any host
+		AllowedRegistries []string         `json:"allowedRegistries"`
+		Banned            []*BannedPackage `json:"banned"`
+		// MinimumVersions maps package base uris (package://pkg.pkl-lang.org/pkl-k8s/k8s) to the lowest allowed version
+		MinimumVersions map[string]string `json:"minimumVersions"`
+	}
+
+	// BannedPackage bans versions of packages matching a base uri pattern, all versions when Versions is empty
+	BannedPackage struct {
+		Package  string `json:"package"`
+		Versions string `json:"versions"`
+		Reason   string `json:"reason"`
+	}
+
+	// PolicyViolationError reports a package rejected by the admission policy and the dependency paths that introduced it
+	PolicyViolationError struct {
+		Uri    string
+		Reason string
+		Paths  [][]string
+	}
+
+	admission struct {
+		hosts      []*regexp.Regexp
+		registries []*regexp.Regexp
+		banned     []bannedVersions
+		minimum    map[string]*semver.Version
+	}
+
+	bannedVersions struct {
+		pattern  *regexp.Regexp
+		versions *semver.Constraints
+		reason   string
+	}
+)
+
+func (e *PolicyViolationError) Error() string {
+	if len(e.Paths) == 0 {
+		return fmt.Sprintf("package %s is not admitted: %s", e.Uri, e.Reason)
+	}
+
+	paths := make([]string, 0, len(e.Paths))
+	for _, path := range e.Paths {
+		paths = append(paths, strings.Join(path, " -> "))
+	}
+
+	return fmt.Sprintf("package %s is not admitted: %s, required by %s", e.Uri, e.Reason, strings.Join(paths, "; "))
+}
+
+func appendParent(parents []string, parent string) []string {
+	for _, p := range parents {
+		if p == parent {
+			return parents
+		}
+	}
+	return append(parents, parent)
+}
+
+// requirementPaths lists every chain of packages from the project to a package depending on uri, sorted
+func requirementPaths(parents map[string][]string, uri string) [][]string {
+	seen := make(map[string]bool)
+	onPath := map[string]bool{uri: true}
+
+	var result [][]string
+
+	var walk func(node string, suffix []string)
+	walk = func(node string, suffix []string) {
+		path := append([]string{node}, suffix...)
+
+		if node == projectRequirement {
+			if key := strings.Join(path, " -> "); !seen[key] {
+				seen[key] = true
+				result = append(result, path)
+			}
+			return
+		}
+
+		onPath[node] = true
+		defer delete(onPath, node)
+
+		for _, parent := range parents[node] {
+			if !onPath[parent] {
+				walk(parent, path)
+			}
+		}
+	}
+
+	for _, parent := range parents[uri] {
+		walk(parent, nil)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return strings.Join(result[i], " -> ") < strings.Join(result[j], " -> ")
+	})
+
+	return result
+}
+
+// newAdmission compiles the policy, nil policy admits every package
+func newAdmission(policy *AdmissionPolicy) (*admission, error) {
+	if policy == nil {
+		return nil, nil
+	}
+
+	a := &admission{minimum: make(map[string]*semver.Version, len(policy.MinimumVersions))}
+
+	for _, host := range policy.AllowedHosts {
+		a.hosts = append(a.hosts, globPattern(host))
+	}
+
+	for _, host := range policy.AllowedRegistries {
+		a.registries = append(a.registries, globPattern(host))
+	}
+
+	for _, banned := range policy.Banned {
+		b := bannedVersions{pattern: globPattern(banned.Package), reason: banned.Reason}
+
+		if banned.Versions != "" {
+			c, err := semver.NewConstraint(banned.Versions)
+			if err != nil {
+				return nil, fmt.Errorf("banned versions %s of %s: %w", banned.Versions, banned.Package, err)
+			}
+			b.versions = c
+		}
+
+		a.banned = append(a.banned, b)
+	}
+
+	for pkg, minimum := range policy.MinimumVersions {
+		v, err := semver.NewVersion(minimum)
+		if err != nil {
+			return nil, fmt.Errorf("minimum version %s of %s: %w", minimum, pkg, err)
+		}
+		a.minimum[pkg] = v
+	}
+
+	return a, nil
+}
+
+func matchesAny(patterns []*regexp.Regexp, host string) bool {
+	for _, pattern := range patterns {
+		if pattern.MatchString(host) {
+			return true
+		}
+	}
+	return false
+}
+
+func uriHost(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return u.Host
+}
+
+// checkRegistry rejects uris fetched from hosts outside of allowed registries
+func (a *admission) checkRegistry(uri string, fetched string) error {
+	if a == nil || len(a.registries) == 0 || fetched == "" {
+		return nil
+	}
+
+	if host := uriHost(fetched); !matchesAny(a.registries, host) {
+		return &PolicyViolationError{Uri: uri, Reason: fmt.Sprintf("registry %s is not allowed", host)}
+	}
+
+	return nil
+}
+
+// checkSource is done before anything of the package is fetched
+func (a *admission) checkSource(source *Source, uri string) error {
+	if a == nil {
+		return nil
+	}
+
+	if host := uriHost(uri); len(a.hosts) > 0 && !matchesAny(a.hosts, host) {
+		return &PolicyViolationError{Uri: uri, Reason: fmt.Sprintf("host %s is not allowed", host)}
+	}
+
+	return a.checkRegistry(uri, metadataLocation(source, uri))
+}
+
+// checkArchive is done with resolved metadata, before the archive is downloaded
+func (a *admission) checkArchive(source *Source, metadata *Metadata) error {
+	if a == nil {
+		return nil
+	}
+
+	return a.checkRegistry(metadata.PackageUri, archiveLocation(source, metadata))
+}
+
+// rejectReason tells why the exact version of the package is not admitted, empty when it is
+func (a *admission) rejectReason(baseUri string, version *semver.Version) string {
+	if a == nil {
+		return ""
+	}
+
+	for _, b := range a.banned {
+		if !b.pattern.MatchString(baseUri) || (b.versions != nil && !b.versions.Check(version)) {
+			continue
+		}
+
+		if b.reason != "" {
+			return "banned, " + b.reason
+		}
+		return "banned"
+	}
+
+	if minimum, ok := a.minimum[baseUri]; ok && version.LessThan(minimum) {
+		return fmt.Sprintf("version is lower than the required minimum %s", minimum.Original())
+	}
+
+	return ""
+}
+
+// checkVersion rejects banned versions and versions below the required minimum
+func (a *admission) checkVersion(uri string) error {
+	if a == nil {
+		return nil
+	}
+
+	baseUri, v := pklutils.PklSplitVersion(uri)
+
+	version, err := semver.NewVersion(v)
+	if err != nil {
+		return nil
+	}
+
+	if reason := a.rejectReason(baseUri, version); reason != "" {
+		return &PolicyViolationError{Uri: uri, Reason: reason}
+	}
+
+	return nil
+}
+
+// admittedVersions drops versions the policy rejects, so version ranges select an admitted release
+func (a *admission) admittedVersions(baseUri string, versions []string) []string {
+	if a == nil {
+		return versions
+	}
+
+	var result []string
+
+	for _, v := range versions {
+		version, err := semver.NewVersion(v)
+		if err == nil && a.rejectReason(baseUri, version) != "" {
+			continue
+		}
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// metadataLocation is the uri metadata of the package is fetched from, empty for local sources
+func metadataLocation(source *Source, uri string) string {
+	switch resolver := source.Resolver.(type) {
+	case *MirrorResolver:
+		mirrored, _ := resolver.rewrite.Apply(uri)
+		return mirrored
+	case *OciResolver, *HttpResolver:
+		return uri
+	default:
+		return ""
+	}
+}
+
+// archiveLocation is the uri the package archive is fetched from, empty for local sources
+func archiveLocation(source *Source, metadata *Metadata) string {
+	switch resolver := source.Resolver.(type) {
+	case *MirrorResolver:
+		if _, ok := resolver.resolver.(*HttpResolver); ok {
+			return applyRewrites(resolver.rewrites, metadata.PackageZipUrl)
+		}
+		mirrored, _ := resolver.rewrite.Apply(metadata.PackageUri)
+		return mirrored
+	case *HttpResolver:
+		return metadata.PackageZipUrl
+	case *OciResolver:
+		return metadata.PackageUri
+	default:
+		return ""
+	}
+}
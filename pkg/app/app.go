@@ -5,10 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/apple/pkl-go/pkl"
+	"hpkl.io/hpkl/pkg/httpclient"
 	"hpkl.io/hpkl/pkg/logger"
 	"hpkl.io/hpkl/pkg/pklutils"
 )
@@ -16,8 +19,23 @@ import (
 type AppConfig struct {
 	Logger          *logger.Logger
 	project         *pkl.Project
+	config          *Config
 	ctx             context.Context
 	PlainHttp       bool
+	VerifyCache     bool
+	Offline         bool
+	Concurrency     int
+	Strategy        string
+	Timeout         time.Duration
+	RequestTimeout  time.Duration
+	Retries         int
+	RetryMaxWait    time.Duration
+	MetadataTtl     time.Duration
+	NoMetadataCache bool
+	PolicyFile      string
+	Transport       httpclient.TransportOptions
+	httpClient      *http.Client
+	httpAuth        *HttpAuth
 	CacheDir        string
 	DefaultCacheDir string
 	WorkingDir      string
@@ -60,8 +78,67 @@ func (a *AppConfig) Project() *pkl.Project {
 	return p
 }
 
+// HttpClient returns the http client shared by package resolvers and registry clients of the working directory.
+// Resolvers take it when they are created, it is not meant to be created by concurrent goroutines.
+func (a *AppConfig) HttpClient() (*http.Client, error) {
+	if a.httpClient == nil {
+		options, err := a.httpOptions()
+		if err != nil {
+			return nil, err
+		}
+
+		client, err := httpclient.New(options)
+		if err != nil {
+			return nil, err
+		}
+
+		a.httpClient = client
+	}
+	return a.httpClient, nil
+}
+
+// TransportOptions returns TLS and proxy settings of connections to host
+func (a *AppConfig) TransportOptions(host string) (httpclient.TransportOptions, error) {
+	options, err := a.httpOptions()
+	if err != nil {
+		return httpclient.TransportOptions{}, err
+	}
+	return options.For(host), nil
+}
+
+// httpOptions combines http settings of hpkl configuration with command line flags, which take precedence
+func (a *AppConfig) httpOptions() (httpclient.Options, error) {
+	options := httpclient.Options{
+		Retries:      a.Retries,
+		RetryMaxWait: a.RetryMaxWait,
+		Logger:       a.Logger,
+	}
+
+	config, err := a.Config()
+	if err != nil {
+		return options, err
+	}
+
+	if config.Http != nil {
+		options.Transport = config.Http.TransportOptions
+		for _, host := range config.Http.Hosts {
+			options.Hosts = append(options.Hosts, *host)
+		}
+	}
+
+	options.Transport = options.Transport.Merge(a.Transport)
+	if a.Transport.CaFile != "" {
+		options.Transport.CaFile = a.Transport.CaFile
+	}
+
+	return options, nil
+}
+
 func (a *AppConfig) Reset() {
 	a.project = nil
+	a.config = nil
+	a.httpAuth = nil
+	a.httpClient = nil
 }
 
 func NewAppConfig(ctx context.Context, outWriter io.Writer, errWriter io.Writer) (*AppConfig, error) {
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+)
+
+type ChecksumError struct {
+	Package  string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected sha256 %s, got %s", e.Package, e.Expected, e.Actual)
+}
+
+func Sha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Sha256Reader hashes a stream without buffering it
+func Sha256Reader(r io.Reader) (string, error) {
+	hash := sha256.New()
+
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// VerifyChecksum compares archive bytes with the sha256 checksum declared in the package metadata
+func VerifyChecksum(metadata *Metadata, data []byte) error {
+	return verifySha256(metadata, Sha256(data))
+}
+
+func verifySha256(metadata *Metadata, actual string) error {
+	expected := metadata.PackageZipChecksums.Sha256
+
+	if expected == "" {
+		return fmt.Errorf("package %s has no sha256 checksum in metadata", metadata.PackageUri)
+	}
+
+	if actual != expected {
+		return &ChecksumError{Package: metadata.PackageUri, Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
+
+// digestReader fails the final read when the stream does not match the expected "sha256:<hex>" digest
+type digestReader struct {
+	io.ReadCloser
+	hash     hash.Hash
+	pkg      string
+	expected string
+}
+
+func newDigestReader(r io.ReadCloser, pkg string, expected string) *digestReader {
+	return &digestReader{ReadCloser: r, hash: sha256.New(), pkg: pkg, expected: expected}
+}
+
+func (r *digestReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.hash.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := "sha256:" + hex.EncodeToString(r.hash.Sum(nil)); actual != r.expected {
+			return n, &ChecksumError{Package: r.pkg, Expected: r.expected, Actual: actual}
+		}
+	}
+
+	return n, err
+}
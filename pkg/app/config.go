@@ -0,0 +1,99 @@
+package app
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"hpkl.io/hpkl/pkg/httpclient"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	// Config is the hpkl configuration read from .hpkl/config.pkl
+	// in the working directory or, if missing there, in the home directory
+	Config struct {
+		Sources  []*SourceConfig   `json:"sources"`
+		Rewrites []*RewriteConfig  `json:"rewrites"`
+		Licenses *LicensePolicy    `json:"licenses"`
+		Policy   *AdmissionPolicy  `json:"policy"`
+		HttpAuth []*HttpAuthConfig `json:"httpAuth"`
+		Http     *HttpConfig       `json:"http"`
+	}
+
+	// HttpConfig holds TLS and proxy settings of registry and https connections, Hosts override them per host.
+	// Relative file paths are relative to the directory of the config file.
+	HttpConfig struct {
+		httpclient.TransportOptions
+		Hosts []*httpclient.HostTransportOptions `json:"hosts"`
+	}
+)
+
+func (a *AppConfig) configFile() (string, error) {
+	candidates := []string{filepath.Join(a.WorkingDir, configPath)}
+
+	if home, err := os.UserHomeDir(); err == nil {
+		candidates = append(candidates, filepath.Join(home, configPath))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+	}
+
+	return "", nil
+}
+
+// Config loads hpkl configuration, an empty configuration is used when no config file exists
+func (a *AppConfig) Config() (*Config, error) {
+	if a.config == nil {
+		config := &Config{}
+
+		path, err := a.configFile()
+		if err != nil {
+			return nil, err
+		}
+
+		if path != "" {
+			if err := pklutils.LoadConfig(a.ctx, path, config); err != nil {
+				a.Logger.Error("Config file path: %s", path)
+				return nil, err
+			}
+
+			if config.Http != nil {
+				dir := filepath.Dir(path)
+				config.Http.ResolvePaths(dir)
+				for _, host := range config.Http.Hosts {
+					host.ResolvePaths(dir)
+				}
+			}
+		}
+
+		a.config = config
+	}
+
+	return a.config, nil
+}
+
+// Policy loads the admission policy from AppConfig.PolicyFile, or the policy of hpkl configuration when no file is given
+func (a *AppConfig) Policy() (*AdmissionPolicy, error) {
+	if a.PolicyFile == "" {
+		config, err := a.Config()
+		if err != nil {
+			return nil, err
+		}
+		return config.Policy, nil
+	}
+
+	policy := &AdmissionPolicy{}
+
+	if err := pklutils.LoadConfig(a.ctx, a.PolicyFile, policy); err != nil {
+		a.Logger.Error("Policy file path: %s", a.PolicyFile)
+		return nil, err
+	}
+
+	return policy, nil
+}
@@ -0,0 +1,348 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/apple/pkl-go/pkl"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	// GraphNode is a dependency with the version it was requested with and the version picked by Deduplicate
+	GraphNode struct {
+		Name         string       `json:"name"`
+		Uri          string       `json:"uri"`
+		Requested    string       `json:"requested"`
+		Resolved     string       `json:"resolved"`
+		Source       string       `json:"source"`
+		Dependencies []*GraphNode `json:"dependencies,omitempty"`
+	}
+)
+
+// Upgraded reports whether deduplication picked a different version from the requested one
+func (n *GraphNode) Upgraded() bool {
+	return n.Requested != n.Resolved
+}
+
+func (n *GraphNode) label() string {
+	if n.Upgraded() {
+		return fmt.Sprintf("%s@%s -> %s", n.Name, n.Requested, n.Resolved)
+	}
+	return fmt.Sprintf("%s@%s", n.Name, n.Resolved)
+}
+
+// Graph builds the dependency tree of a project from the already resolved and deduplicated metadata,
+// local projects are shown with their own dependencies
+func (r *Resolver) Graph(ctx context.Context, name string, dependencies *pkl.ProjectDependencies, deduplicated map[string]*Metadata) (*GraphNode, error) {
+	chosen := make(map[string]*Metadata, len(deduplicated))
+
+	for _, m := range deduplicated {
+		key, err := r.MajorVersionPackage(m)
+		if err != nil {
+			return nil, err
+		}
+		chosen[key] = m
+	}
+
+	children, err := r.projectGraphNodes(ctx, dependencies, chosen, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+
+	return &GraphNode{Name: name, Source: "project", Dependencies: children}, nil
+}
+
+// projectGraphNodes builds the nodes of local and remote dependencies of a project
+func (r *Resolver) projectGraphNodes(ctx context.Context, dependencies *pkl.ProjectDependencies, chosen map[string]*Metadata, path map[string]bool) ([]*GraphNode, error) {
+	if dependencies == nil {
+		return nil, nil
+	}
+
+	var nodes []*GraphNode
+
+	for name, dep := range dependencies.LocalDependencies {
+		_, version := pklutils.PklSplitVersion(dep.PackageUri)
+		node := &GraphNode{
+			Name:      name,
+			Uri:       dep.PackageUri,
+			Requested: version,
+			Resolved:  version,
+			Source:    "local",
+		}
+
+		if !path[dep.PackageUri] {
+			path[dep.PackageUri] = true
+
+			children, err := r.projectGraphNodes(ctx, dep.Dependencies, chosen, path)
+			if err != nil {
+				return nil, err
+			}
+			node.Dependencies = children
+
+			delete(path, dep.PackageUri)
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	for name, dep := range dependencies.RemoteDependencies {
+		node, err := r.graphNode(ctx, Dependency{Name: name, Uri: dep.PackageUri}, chosen, map[string]bool{})
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	sortGraphNodes(nodes)
+
+	return nodes, nil
+}
+
+func (r *Resolver) graphNode(ctx context.Context, dependency Dependency, chosen map[string]*Metadata, path map[string]bool) (*GraphNode, error) {
+	uri, metadata, err := r.resolveDependency(ctx, dependency)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := r.MajorVersionPackage(metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := metadata.Version
+	if m, ok := chosen[key]; ok {
+		resolved = m.Version
+	}
+
+	_, requested := pklutils.PklSplitVersion(dependency.Uri)
+
+	node := &GraphNode{
+		Name:      dependency.Name,
+		Uri:       dependency.Uri,
+		Requested: requested,
+		Resolved:  resolved,
+		Source:    metadata.Source.Type,
+	}
+
+	// cyclic dependencies are shown once per path
+	if path[uri] {
+		return node, nil
+	}
+	path[uri] = true
+	defer delete(path, uri)
+
+	for _, sub := range metadata.Dependencies {
+		child, err := r.graphNode(ctx, sub, chosen, path)
+		if err != nil {
+			return nil, err
+		}
+		node.Dependencies = append(node.Dependencies, child)
+	}
+
+	sortGraphNodes(node.Dependencies)
+
+	return node, nil
+}
+
+func sortGraphNodes(nodes []*GraphNode) {
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Name != nodes[j].Name {
+			return nodes[i].Name < nodes[j].Name
+		}
+		return nodes[i].Uri < nodes[j].Uri
+	})
+}
+
+func WriteGraphText(w io.Writer, root *GraphNode) error {
+	if _, err := fmt.Fprintln(w, root.Name); err != nil {
+		return err
+	}
+	return writeGraphTextChildren(w, root.Dependencies, "")
+}
+
+func writeGraphTextChildren(w io.Writer, nodes []*GraphNode, prefix string) error {
+	for i, node := range nodes {
+		branch, indent := "├── ", "│   "
+		if i == len(nodes)-1 {
+			branch, indent = "└── ", "    "
+		}
+
+		if _, err := fmt.Fprintf(w, "%s%s%s [%s] %s\n", prefix, branch, node.label(), node.Source, node.Uri); err != nil {
+			return err
+		}
+
+		if err := writeGraphTextChildren(w, node.Dependencies, prefix+indent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func WriteGraphJson(w io.Writer, root *GraphNode) error {
+	data, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
+
+// graphEdges flattens the tree into unique nodes and edges for DOT and Mermaid output
+func graphEdges(root *GraphNode) ([]*GraphNode, [][2]int) {
+	ids := map[string]int{}
+	var nodes []*GraphNode
+	var edges [][2]int
+	seen := map[[2]int]bool{}
+
+	id := func(n *GraphNode) int {
+		key := n.Uri + "|" + n.Resolved
+		if n == root {
+			key = ""
+		}
+		if i, ok := ids[key]; ok {
+			return i
+		}
+		ids[key] = len(nodes)
+		nodes = append(nodes, n)
+		return ids[key]
+	}
+
+	var walk func(n *GraphNode)
+	walk = func(n *GraphNode) {
+		from := id(n)
+		for _, child := range n.Dependencies {
+			edge := [2]int{from, id(child)}
+			if !seen[edge] {
+				seen[edge] = true
+				edges = append(edges, edge)
+				walk(child)
+			}
+		}
+	}
+	walk(root)
+
+	return nodes, edges
+}
+
+func WriteGraphDot(w io.Writer, root *GraphNode) error {
+	nodes, edges := graphEdges(root)
+
+	var sb strings.Builder
+	sb.WriteString("digraph dependencies {\n")
+	for i, n := range nodes {
+		label := n.Name
+		if n != root {
+			label = fmt.Sprintf("%s\n%s", n.label(), n.Source)
+		}
+		fmt.Fprintf(&sb, "  n%d [label=%q];\n", i, label)
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&sb, "  n%d -> n%d;\n", e[0], e[1])
+	}
+	sb.WriteString("}\n")
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+func WriteGraphMermaid(w io.Writer, root *GraphNode) error {
+	nodes, edges := graphEdges(root)
+
+	var sb strings.Builder
+	sb.WriteString("graph TD\n")
+	for i, n := range nodes {
+		label := n.Name
+		if n != root {
+			label = fmt.Sprintf("%s (%s)", n.label(), n.Source)
+		}
+		fmt.Fprintf(&sb, "  n%d[\"%s\"]\n", i, strings.ReplaceAll(label, "\"", "#quot;"))
+	}
+	for _, e := range edges {
+		fmt.Fprintf(&sb, "  n%d --> n%d\n", e[0], e[1])
+	}
+
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// MinorUpgrade reports whether deduplication upgraded the requested version to a newer minor version
+func (n *GraphNode) MinorUpgrade() bool {
+	requested, err := semver.NewVersion(n.Requested)
+	if err != nil {
+		return false
+	}
+	resolved, err := semver.NewVersion(n.Resolved)
+	if err != nil {
+		return false
+	}
+	return resolved.Major() == requested.Major() && resolved.Minor() > requested.Minor()
+}
+
+// Matches reports whether the node is the package given by name, path or uri, with optional @version
+func (n *GraphNode) Matches(query string) bool {
+	if query == n.Name || query == n.Uri {
+		return true
+	}
+
+	base, _ := pklutils.PklSplitVersion(n.Uri)
+	queryBase, queryVersion := pklutils.PklSplitVersion(query)
+
+	if base != queryBase && !strings.HasSuffix(base, "/"+queryBase) {
+		return false
+	}
+
+	return queryVersion == "" || queryVersion == n.Requested || queryVersion == n.Resolved
+}
+
+// GraphPaths returns every path from the root to nodes matching the query
+func GraphPaths(root *GraphNode, query string) [][]*GraphNode {
+	var paths [][]*GraphNode
+
+	var walk func(n *GraphNode, path []*GraphNode)
+	walk = func(n *GraphNode, path []*GraphNode) {
+		path = append(path, n)
+		if n != root && n.Matches(query) {
+			paths = append(paths, append([]*GraphNode{}, path...))
+		}
+		for _, child := range n.Dependencies {
+			walk(child, path)
+		}
+	}
+	walk(root, nil)
+
+	return paths
+}
+
+func WritePathsText(w io.Writer, paths [][]*GraphNode) error {
+	for _, path := range paths {
+		labels := make([]string, len(path))
+		var notes []string
+
+		for i, n := range path {
+			if i == 0 {
+				labels[i] = n.Name
+				continue
+			}
+			labels[i] = n.label()
+			if n.MinorUpgrade() {
+				notes = append(notes, fmt.Sprintf("%s upgraded from %s to newer minor version %s", n.Name, n.Requested, n.Resolved))
+			}
+		}
+
+		line := strings.Join(labels, " > ")
+		if len(notes) > 0 {
+			line += " (" + strings.Join(notes, "; ") + ")"
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
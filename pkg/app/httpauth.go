@@ -0,0 +1,195 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const httpEnvPrefix = "HPKL_HTTP_"
+
+type (
+	// HttpAuthConfig holds credentials of https package hosts matching Host (gitlab.example.com, *.internal).
+	// Token is sent as bearer token, Username and Password as basic auth, Headers as they are,
+	// e.g. PRIVATE-TOKEN of GitLab or X-JFrog-Art-Api of Artifactory.
+	HttpAuthConfig struct {
+		Host     string            `json:"host"`
+		Username string            `json:"username"`
+		Password string            `json:"password"`
+		Token    string            `json:"token"`
+		Headers  map[string]string `json:"headers"`
+	}
+
+	// HttpAuth finds credentials of a host in hpkl configuration, HPKL_HTTP_TOKEN_<HOST>,
+	// HPKL_HTTP_USERNAME_<HOST> and HPKL_HTTP_PASSWORD_<HOST> environment variables and netrc, in this order
+	HttpAuth struct {
+		configs  []*HttpAuthConfig
+		patterns []*regexp.Regexp
+		netrc    map[string]*HttpAuthConfig
+		getenv   func(string) string
+	}
+)
+
+func NewHttpAuth(configs []*HttpAuthConfig, netrcFile string) (*HttpAuth, error) {
+	auth := &HttpAuth{configs: configs, getenv: os.Getenv}
+
+	for _, c := range configs {
+		auth.patterns = append(auth.patterns, globPattern(c.Host))
+	}
+
+	if netrcFile != "" {
+		data, err := os.ReadFile(netrcFile)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		auth.netrc = parseNetrc(string(data))
+	}
+
+	return auth, nil
+}
+
+// netrcFile is $NETRC or .netrc in the home directory
+func netrcFile() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".netrc")
+}
+
+// parseNetrc reads machine login and password entries, the default entry is ignored
+// as its credentials would be sent to any host
+func parseNetrc(data string) map[string]*HttpAuthConfig {
+	result := make(map[string]*HttpAuthConfig)
+
+	var current *HttpAuthConfig
+	var macro bool
+
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// a macro definition ends with an empty line
+		if macro {
+			macro = strings.TrimSpace(line) != ""
+			continue
+		}
+
+		fields := strings.Fields(line)
+
+		for i := 0; i < len(fields); i++ {
+			value := ""
+			if i+1 < len(fields) {
+				value = fields[i+1]
+			}
+
+			switch fields[i] {
+			case "machine":
+				current = &HttpAuthConfig{Host: value}
+				if _, ok := result[value]; !ok {
+					result[value] = current
+				}
+				i++
+			case "default":
+				current = nil
+			case "login":
+				if current != nil {
+					current.Username = value
+				}
+				i++
+			case "password":
+				if current != nil {
+					current.Password = value
+				}
+				i++
+			case "account":
+				i++
+			case "macdef":
+				macro = true
+				i = len(fields)
+			}
+		}
+	}
+
+	return result
+}
+
+// envHost turns a host into the suffix of its environment variables, gitlab.example.com:8443 -> GITLAB_EXAMPLE_COM_8443
+func envHost(host string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, host)
+}
+
+// For returns credentials of host, which may include a port, nil when there are none
+func (a *HttpAuth) For(host string) *HttpAuthConfig {
+	hostname := (&url.URL{Host: host}).Hostname()
+
+	for i, pattern := range a.patterns {
+		if pattern.MatchString(host) || pattern.MatchString(hostname) {
+			return a.configs[i]
+		}
+	}
+
+	suffix := envHost(host)
+
+	if token := a.getenv(httpEnvPrefix + "TOKEN_" + suffix); token != "" {
+		return &HttpAuthConfig{Host: host, Token: token}
+	}
+
+	if username := a.getenv(httpEnvPrefix + "USERNAME_" + suffix); username != "" {
+		return &HttpAuthConfig{Host: host, Username: username, Password: a.getenv(httpEnvPrefix + "PASSWORD_" + suffix)}
+	}
+
+	return a.netrc[hostname]
+}
+
+// apply sets the credentials on the request
+func (c *HttpAuthConfig) apply(req *http.Request) {
+	switch {
+	case c.Token != "":
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	case c.Username != "" || c.Password != "":
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+
+	for name, value := range c.Headers {
+		req.Header.Set(name, value)
+	}
+}
+
+// HttpAuth returns credentials of https package hosts, loaded once
+func (a *AppConfig) HttpAuth() (*HttpAuth, error) {
+	if a.httpAuth == nil {
+		config, err := a.Config()
+		if err != nil {
+			return nil, err
+		}
+
+		auth, err := NewHttpAuth(config.HttpAuth, netrcFile())
+		if err != nil {
+			return nil, err
+		}
+
+		a.httpAuth = auth
+	}
+
+	return a.httpAuth, nil
+}
@@ -0,0 +1,238 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	// LicensePolicy restricts licenses of remote dependencies, configured as `licenses` in .hpkl/config.pkl.
+	// Allow and Deny contain SPDX license identifiers, an empty Allow accepts every license not denied.
+	LicensePolicy struct {
+		Allow []string `json:"allow"`
+		Deny  []string `json:"deny"`
+	}
+
+	// PackageLicense is the declared license of a resolved package and the policy violation, if any
+	PackageLicense struct {
+		Name       string `json:"name"`
+		Version    string `json:"version"`
+		PackageUri string `json:"packageUri"`
+		License    string `json:"license"`
+		Violation  string `json:"violation,omitempty"`
+	}
+)
+
+// Check returns an error when license, an SPDX license expression, is not acceptable.
+// For OR expressions one accepted alternative is enough, AND expressions need all licenses accepted.
+func (p *LicensePolicy) Check(license string) error {
+	if strings.TrimSpace(license) == "" {
+		return errors.New("declares no license")
+	}
+
+	parser := &licenseParser{tokens: licenseTokens(license), accept: p.accepts}
+
+	ok, err := parser.parse()
+	if err != nil {
+		return fmt.Errorf("invalid license expression %q: %w", license, err)
+	}
+
+	if !ok {
+		return fmt.Errorf("license %s is not allowed", license)
+	}
+
+	return nil
+}
+
+func (p *LicensePolicy) accepts(id string) bool {
+	for _, denied := range p.Deny {
+		if strings.EqualFold(denied, id) {
+			return false
+		}
+	}
+
+	if len(p.Allow) == 0 {
+		return true
+	}
+
+	for _, allowed := range p.Allow {
+		if strings.EqualFold(allowed, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// licenseParser evaluates an SPDX license expression, AND binds tighter than OR
+type licenseParser struct {
+	tokens []string
+	pos    int
+	accept func(id string) bool
+}
+
+func licenseTokens(expression string) []string {
+	expression = strings.NewReplacer("(", " ( ", ")", " ) ").Replace(expression)
+	return strings.Fields(expression)
+}
+
+func (p *licenseParser) parse() (bool, error) {
+	ok, err := p.or()
+	if err != nil {
+		return false, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return false, fmt.Errorf("unexpected %s", p.tokens[p.pos])
+	}
+
+	return ok, nil
+}
+
+func (p *licenseParser) next() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *licenseParser) or() (bool, error) {
+	result, err := p.and()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.next(), "OR") {
+		p.pos++
+		ok, err := p.and()
+		if err != nil {
+			return false, err
+		}
+		result = result || ok
+	}
+
+	return result, nil
+}
+
+func (p *licenseParser) and() (bool, error) {
+	result, err := p.term()
+	if err != nil {
+		return false, err
+	}
+
+	for strings.EqualFold(p.next(), "AND") {
+		p.pos++
+		ok, err := p.term()
+		if err != nil {
+			return false, err
+		}
+		result = result && ok
+	}
+
+	return result, nil
+}
+
+func (p *licenseParser) term() (bool, error) {
+	token := p.next()
+
+	switch {
+	case token == "":
+		return false, errors.New("unexpected end of expression")
+	case token == "(":
+		p.pos++
+		ok, err := p.or()
+		if err != nil {
+			return false, err
+		}
+		if p.next() != ")" {
+			return false, errors.New("missing )")
+		}
+		p.pos++
+		return ok, nil
+	case token == ")" || strings.EqualFold(token, "AND") || strings.EqualFold(token, "OR") || strings.EqualFold(token, "WITH"):
+		return false, fmt.Errorf("unexpected %s", token)
+	}
+
+	p.pos++
+
+	// the exception of `license WITH exception` does not change which license applies
+	if strings.EqualFold(p.next(), "WITH") {
+		p.pos++
+		if exception := p.next(); exception == "" || exception == "(" || exception == ")" {
+			return false, errors.New("missing license exception")
+		}
+		p.pos++
+	}
+
+	return p.accept(token), nil
+}
+
+// PackageLicenses lists licenses of deduplicated packages sorted by package uri,
+// violations are only reported when a policy is given
+func PackageLicenses(deduplicated map[string]*Metadata, policy *LicensePolicy) []*PackageLicense {
+	result := make([]*PackageLicense, 0, len(deduplicated))
+
+	for _, m := range deduplicated {
+		l := &PackageLicense{Name: m.Name, Version: m.Version, PackageUri: m.PackageUri, License: m.License}
+
+		if policy != nil {
+			if err := policy.Check(m.License); err != nil {
+				l.Violation = err.Error()
+			}
+		}
+
+		result = append(result, l)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PackageUri < result[j].PackageUri
+	})
+
+	return result
+}
+
+// CheckLicenses fails when a deduplicated package violates the license policy of the hpkl configuration
+func (r *Resolver) CheckLicenses(deduplicated map[string]*Metadata) error {
+	config, err := r.config.Config()
+	if err != nil {
+		return err
+	}
+
+	if config.Licenses == nil {
+		return nil
+	}
+
+	var errs []error
+
+	for _, l := range PackageLicenses(deduplicated, config.Licenses) {
+		if l.Violation != "" {
+			errs = append(errs, fmt.Errorf("%s %s", l.PackageUri, l.Violation))
+		}
+	}
+
+	return joinErrors(errs)
+}
+
+func WriteLicenseTable(w io.Writer, licenses []*PackageLicense) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PACKAGE\tVERSION\tLICENSE\tVIOLATION")
+	for _, l := range licenses {
+		baseUri, _ := pklutils.PklSplitVersion(l.PackageUri)
+
+		license := l.License
+		if license == "" {
+			license = "-"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", baseUri, l.Version, license, l.Violation)
+	}
+
+	return tw.Flush()
+}
@@ -0,0 +1,108 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	lockFileName      = ".hpkl.lock"
+	lockRetryInterval = 100 * time.Millisecond
+)
+
+// fileLock is an exclusive advisory lock shared between hpkl processes
+type fileLock struct {
+	file *os.File
+}
+
+// lockDir takes the lock of a cache directory, waiting for other processes holding it until ctx is done.
+// The directory is created when missing, e.g. after the previous holder removed it together with the lock file.
+func lockDir(ctx context.Context, dir string) (*fileLock, error) {
+	path := filepath.Join(dir, lockFileName)
+
+	for {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return nil, err
+		}
+
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := waitLock(ctx, file); err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		// the lock is only valid while the file is still in place, otherwise it is taken on the new file
+		if !lockFileRemoved(file, path) {
+			return &fileLock{file: file}, nil
+		}
+
+		unlockFile(file)
+		file.Close()
+	}
+}
+
+func waitLock(ctx context.Context, file *os.File) error {
+	for {
+		locked, err := tryLockFile(file)
+
+		if err != nil || locked {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+func lockFileRemoved(file *os.File, path string) bool {
+	locked, err := file.Stat()
+	if err != nil {
+		return true
+	}
+
+	current, err := os.Stat(path)
+
+	return err != nil || !os.SameFile(locked, current)
+}
+
+// Unlock releases the lock, the lock file is kept for later downloads of the package
+func (l *fileLock) Unlock() error {
+	err := unlockFile(l.file)
+
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Remove deletes the lock file and its directory unless other files are left in it, then releases the lock
+func (l *fileLock) Remove() error {
+	err := os.Remove(l.file.Name())
+
+	if err == nil {
+		// fails when the directory is not empty
+		os.Remove(filepath.Dir(l.file.Name()))
+	}
+
+	if unlockErr := l.Unlock(); err == nil {
+		err = unlockErr
+	}
+
+	return err
+}
@@ -0,0 +1,185 @@
+package app
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Masterminds/semver/v3"
+	"hpkl.io/hpkl/pkg/logger"
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	// MetadataCache persists resolved package metadata and version listings between runs
+	MetadataCache struct {
+		dir    string
+		ttl    time.Duration
+		logger *logger.Logger
+	}
+
+	metadataCacheEntry struct {
+		Uri       string          `json:"uri"`
+		Validator string          `json:"validator,omitempty"`
+		FetchedAt time.Time       `json:"fetchedAt"`
+		Data      json.RawMessage `json:"data"`
+	}
+
+	// RevalidatingResolver is implemented by resolvers able to check whether cached metadata is still current.
+	// RevalidateMetadata returns nil metadata when the package behind the validator has not changed,
+	// otherwise the fresh metadata and its new validator
+	RevalidatingResolver interface {
+		RevalidateMetadata(ctx context.Context, uri string, validator string) (*Metadata, string, error)
+	}
+)
+
+func NewMetadataCache(cacheDir string, ttl time.Duration, logger *logger.Logger) *MetadataCache {
+	return &MetadataCache{dir: filepath.Join(cacheDir, "hpkl", "metadata"), ttl: ttl, logger: logger}
+}
+
+func (c *MetadataCache) path(kind string, uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.dir, kind, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *MetadataCache) get(kind string, uri string) *metadataCacheEntry {
+	data, err := os.ReadFile(c.path(kind, uri))
+
+	if err != nil {
+		return nil
+	}
+
+	var entry metadataCacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.Uri != uri {
+		return nil
+	}
+
+	return &entry
+}
+
+// store writes the entry, a read-only or full cache only costs the next fetch, so failures are just reported
+func (c *MetadataCache) store(kind string, uri string, validator string, value any) {
+	if err := c.put(kind, uri, validator, value); err != nil {
+		c.logger.Warn("Could not cache metadata of %s: %s", uri, err)
+	}
+}
+
+func (c *MetadataCache) put(kind string, uri string, validator string, value any) error {
+	data, err := json.Marshal(value)
+
+	if err != nil {
+		return err
+	}
+
+	entry, err := json.Marshal(&metadataCacheEntry{
+		Uri:       uri,
+		Validator: validator,
+		FetchedAt: time.Now(),
+		Data:      data,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	path := c.path(kind, uri)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	return writeFileAtomic(path, entry)
+}
+
+func (c *MetadataCache) fresh(entry *metadataCacheEntry) bool {
+	return c.ttl > 0 && time.Since(entry.FetchedAt) < c.ttl
+}
+
+// cacheableSource reports whether metadata of a source is worth caching, local sources are read directly
+func cacheableSource(source *Source) bool {
+	switch source.Type {
+	case SourceFile, SourceDir, SourceCache:
+		return false
+	}
+	return true
+}
+
+// immutableVersion reports whether the package behind uri can never change, i.e. it is a released version
+func immutableVersion(uri string) bool {
+	_, version := pklutils.PklSplitVersion(uri)
+	v, err := semver.StrictNewVersion(version)
+	return err == nil && v.Prerelease() == ""
+}
+
+// Metadata returns cached metadata of uri, released versions are served from the cache unconditionally,
+// others once the ttl expired are revalidated when the resolver supports it or fetched again
+func (c *MetadataCache) Metadata(ctx context.Context, resolver DependencyResolver, uri string) (*Metadata, error) {
+	entry := c.get("packages", uri)
+
+	if entry != nil && (immutableVersion(uri) || c.fresh(entry)) {
+		var metadata *Metadata
+		if err := json.Unmarshal(entry.Data, &metadata); err == nil {
+			return metadata, nil
+		}
+		entry = nil
+	}
+
+	revalidating, ok := resolver.(RevalidatingResolver)
+
+	if !ok {
+		metadata, err := resolver.ResolveMetadata(ctx, uri)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.store("packages", uri, "", metadata)
+
+		return metadata, nil
+	}
+
+	validator := ""
+	if entry != nil {
+		validator = entry.Validator
+	}
+
+	metadata, validator, err := revalidating.RevalidateMetadata(ctx, uri, validator)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if metadata == nil {
+		if err := json.Unmarshal(entry.Data, &metadata); err != nil {
+			return nil, err
+		}
+	}
+
+	c.store("packages", uri, validator, metadata)
+
+	return metadata, nil
+}
+
+// Versions returns the cached version listing of baseUri while it is younger than the ttl
+func (c *MetadataCache) Versions(ctx context.Context, resolver DependencyResolver, baseUri string) ([]string, error) {
+	if entry := c.get("versions", baseUri); entry != nil && c.fresh(entry) {
+		var versions []string
+		if err := json.Unmarshal(entry.Data, &versions); err == nil {
+			return versions, nil
+		}
+	}
+
+	versions, err := resolver.ResolveVersions(ctx, baseUri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	c.store("versions", baseUri, "", versions)
+
+	return versions, nil
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type (
+	// RewriteConfig redirects package fetches, e.g. package://pkg.pkl-lang.org/* -> oci://harbor.internal/pkl-mirror/*.
+	// Every * in To is replaced by the text matched by the corresponding * in From.
+	RewriteConfig struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		PlainHttp bool   `json:"plainHttp"`
+	}
+
+	Rewrite struct {
+		From    string
+		To      string
+		pattern *regexp.Regexp
+	}
+
+	// MirrorResolver fetches packages from rewritten locations, while metadata keeps the original package uri,
+	// so PklProject.deps.json and the cache layout do not depend on the mirror
+	MirrorResolver struct {
+		rewrite  *Rewrite
+		rewrites []*Rewrite
+		resolver DependencyResolver
+	}
+)
+
+func NewRewrite(from string, to string) (*Rewrite, error) {
+	if !strings.Contains(from, "://") || !strings.Contains(to, "://") {
+		return nil, fmt.Errorf("rewrite %s -> %s must use full uris", from, to)
+	}
+
+	return &Rewrite{From: from, To: to, pattern: globPattern(from)}, nil
+}
+
+// Apply rewrites the uri when it matches the rule
+func (r *Rewrite) Apply(uri string) (string, bool) {
+	match := r.pattern.FindStringSubmatch(uri)
+
+	if match == nil {
+		return uri, false
+	}
+
+	parts := strings.Split(r.To, "*")
+
+	var sb strings.Builder
+	for i, part := range parts {
+		sb.WriteString(part)
+		if i < len(parts)-1 && i+1 < len(match) {
+			sb.WriteString(match[i+1])
+		}
+	}
+
+	return sb.String(), true
+}
+
+func applyRewrites(rewrites []*Rewrite, uri string) string {
+	for _, rewrite := range rewrites {
+		if rewritten, ok := rewrite.Apply(uri); ok {
+			return rewritten
+		}
+	}
+	return uri
+}
+
+// newMirrorSource creates source for the rewrite, the scheme of the target uri selects the transport
+func newMirrorSource(appConfig *AppConfig, config *RewriteConfig) (*Source, *Rewrite, error) {
+	rewrite, err := NewRewrite(config.From, config.To)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	target, err := url.Parse(strings.ReplaceAll(config.To, "*", ""))
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var resolver DependencyResolver
+	sourceType := target.Scheme
+
+	switch target.Scheme {
+	case SourceOci:
+		resolver, err = NewOciResolver(appConfig, config.PlainHttp || appConfig.PlainHttp)
+	case SourceHttps:
+		resolver, err = NewHttpResolver(appConfig, appConfig.PlainHttp)
+	case SourceHttp:
+		resolver, err = NewHttpResolver(appConfig, true)
+	default:
+		return nil, nil, fmt.Errorf("unsupported rewrite target scheme %s in %s", target.Scheme, config.To)
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Source{
+		Type:     sourceType,
+		Match:    config.From,
+		Resolver: &MirrorResolver{rewrite: rewrite, resolver: resolver},
+		pattern:  rewrite.pattern,
+	}, rewrite, nil
+}
+
+func (r *MirrorResolver) ResolveMetadata(ctx context.Context, uri string) (*Metadata, error) {
+	mirrored, _ := r.rewrite.Apply(uri)
+
+	metadata, err := r.resolver.ResolveMetadata(ctx, mirrored)
+
+	if err != nil {
+		return nil, err
+	}
+
+	metadata.PackageUri = uri
+
+	return metadata, nil
+}
+
+func (r *MirrorResolver) RevalidateMetadata(ctx context.Context, uri string, validator string) (*Metadata, string, error) {
+	revalidating, ok := r.resolver.(RevalidatingResolver)
+
+	if !ok {
+		metadata, err := r.ResolveMetadata(ctx, uri)
+		return metadata, "", err
+	}
+
+	mirrored, _ := r.rewrite.Apply(uri)
+
+	metadata, validator, err := revalidating.RevalidateMetadata(ctx, mirrored, validator)
+
+	if err != nil || metadata == nil {
+		return nil, validator, err
+	}
+
+	metadata.PackageUri = uri
+
+	return metadata, validator, nil
+}
+
+func (r *MirrorResolver) ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error) {
+	mirrored := *metadata
+	mirrored.PackageUri, _ = r.rewrite.Apply(metadata.PackageUri)
+	mirrored.PackageZipUrl = applyRewrites(r.rewrites, metadata.PackageZipUrl)
+
+	return r.resolver.ResolveArchive(ctx, &mirrored)
+}
+
+func (r *MirrorResolver) ResolveVersions(ctx context.Context, uri string) ([]string, error) {
+	mirrored, _ := r.rewrite.Apply(uri)
+
+	return r.resolver.ResolveVersions(ctx, mirrored)
+}
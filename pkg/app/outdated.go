@@ -0,0 +1,90 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+type (
+	OutdatedPackage struct {
+		Name             string `json:"name"`
+		PackageUri       string `json:"packageUri"`
+		Source           string `json:"source"`
+		Locked           string `json:"locked"`
+		LatestCompatible string `json:"latestCompatible"`
+		Latest           string `json:"latest"`
+	}
+)
+
+// Outdated reports whether a newer compatible or major version is available
+func (p *OutdatedPackage) Outdated() bool {
+	return p.Locked != p.LatestCompatible || p.Locked != p.Latest
+}
+
+// AvailableVersions lists versions of the package from its source
+func (r *Resolver) AvailableVersions(ctx context.Context, metadata *Metadata) ([]string, error) {
+	baseUri, _ := pklutils.PklSplitVersion(metadata.PackageUri)
+
+	return r.sourceVersions(ctx, metadata.Source, baseUri)
+}
+
+// Outdated compares the locked versions of deduplicated dependencies with the versions available in their sources,
+// locked maps major version package uris (package://host/path@1) to locked versions
+func (r *Resolver) Outdated(ctx context.Context, deduplicated map[string]*Metadata, locked map[string]string) ([]*OutdatedPackage, error) {
+	var result []*OutdatedPackage
+
+	for _, m := range deduplicated {
+		key, err := r.MajorVersionPackage(m)
+		if err != nil {
+			return nil, err
+		}
+
+		version, ok := locked[key]
+		if !ok {
+			version = m.Version
+		}
+
+		versions, err := r.AvailableVersions(ctx, m)
+		if err != nil {
+			return nil, err
+		}
+
+		_, compatible, latest, err := UpgradeCandidates(versions, version)
+		if err != nil {
+			return nil, err
+		}
+
+		baseUri, _ := pklutils.PklSplitVersion(m.PackageUri)
+
+		result = append(result, &OutdatedPackage{
+			Name:             m.Name,
+			PackageUri:       baseUri,
+			Source:           m.Source.Type,
+			Locked:           version,
+			LatestCompatible: compatible,
+			Latest:           latest,
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PackageUri < result[j].PackageUri
+	})
+
+	return result, nil
+}
+
+func WriteOutdatedTable(w io.Writer, packages []*OutdatedPackage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PACKAGE\tLOCKED\tCOMPATIBLE\tLATEST\tSOURCE")
+	for _, p := range packages {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.PackageUri, p.Locked, p.LatestCompatible, p.Latest, p.Source)
+	}
+
+	return tw.Flush()
+}
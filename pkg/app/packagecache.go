@@ -0,0 +1,216 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+const packageCacheDirName = "package-2"
+
+// CachedPackage is a package version stored in the package cache
+type CachedPackage struct {
+	Name       string    `json:"name"`
+	Version    string    `json:"version"`
+	PackageUri string    `json:"packageUri"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	Modified   time.Time `json:"modified"`
+	metadata   *Metadata
+	archive    string
+}
+
+// PackageCacheDir returns the directory packages are downloaded to, the layout is shared with pkl
+func PackageCacheDir(cacheDir string) string {
+	return filepath.Join(cacheDir, packageCacheDirName)
+}
+
+// ListCachedPackages walks the package cache and returns the stored package versions sorted by uri,
+// files which do not follow the layout written by Resolver.Download are ignored
+func ListCachedPackages(cacheDir string) ([]*CachedPackage, error) {
+	root := PackageCacheDir(cacheDir)
+	var result []*CachedPackage
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && path == root {
+			return filepath.SkipDir
+		}
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".") || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		metadata, err := readMetadata(path)
+		if err != nil || metadata.PackageUri == "" {
+			return nil
+		}
+
+		packageUri, err := url.Parse(metadata.PackageUri)
+		if err != nil || pklutils.PklGetRelativePath(root, packageUri) != filepath.Dir(path) {
+			return nil
+		}
+
+		p := &CachedPackage{
+			Name:       metadata.Name,
+			Version:    metadata.Version,
+			PackageUri: metadata.PackageUri,
+			Path:       filepath.Dir(path),
+			metadata:   metadata,
+			archive:    strings.TrimSuffix(path, ".json") + ".zip",
+		}
+
+		entries, err := os.ReadDir(p.Path)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || !info.Mode().IsRegular() || entry.Name() == lockFileName {
+				continue
+			}
+			p.Size += info.Size()
+			if info.ModTime().After(p.Modified) {
+				p.Modified = info.ModTime()
+			}
+		}
+
+		result = append(result, p)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PackageUri < result[j].PackageUri
+	})
+
+	return result, nil
+}
+
+// Verify re-hashes the cached archive against the checksum of the stored metadata
+func (p *CachedPackage) Verify() error {
+	file, err := os.Open(p.archive)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	actual, err := Sha256Reader(file)
+
+	if err != nil {
+		return err
+	}
+
+	return verifySha256(p.metadata, actual)
+}
+
+// Remove deletes the package version holding the lock used by Resolver.Download,
+// empty parent directories up to the cache root are removed as well
+func (p *CachedPackage) Remove(ctx context.Context, cacheDir string) error {
+	lock, err := lockDir(ctx, p.Path)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(p.Path)
+	lock.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	root := PackageCacheDir(cacheDir)
+
+	for dir := filepath.Dir(p.Path); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// packageKey identifies a package version regardless of the uri scheme, e.g. host/path@1.0.0
+func packageKey(uri string) string {
+	u, err := url.Parse(uri)
+
+	if err != nil {
+		return uri
+	}
+
+	return u.Host + u.Path
+}
+
+// ReferencedPackages collects remote packages locked in PklProject.deps.json of the given project directories
+func ReferencedPackages(projectDirs []string) (map[string]bool, error) {
+	result := make(map[string]bool)
+
+	for _, dir := range projectDirs {
+		deps, err := pklutils.PklReadDeps(dir)
+
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", dir, err)
+		}
+
+		for _, dep := range deps.ResolvedDependencies {
+			if dep.DependencyType == "remote" {
+				result[packageKey(dep.Uri)] = true
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Referenced reports whether the package version is in the set returned by ReferencedPackages
+func (p *CachedPackage) Referenced(referenced map[string]bool) bool {
+	return referenced[packageKey(p.PackageUri)]
+}
+
+func FormatSize(size int64) string {
+	const unit = 1024
+
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func WriteCacheTable(w io.Writer, packages []*CachedPackage) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "PACKAGE\tVERSION\tSIZE\tMODIFIED")
+	for _, p := range packages {
+		baseUri, _ := pklutils.PklSplitVersion(p.PackageUri)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", baseUri, p.Version, FormatSize(p.Size), p.Modified.Format(time.DateOnly))
+	}
+
+	return tw.Flush()
+}
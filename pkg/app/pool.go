@@ -0,0 +1,90 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type (
+	// flight memoizes results by key and deduplicates concurrent in-flight calls for the same key
+	flight[T any] struct {
+		mu    sync.Mutex
+		calls map[string]*flightCall[T]
+	}
+
+	flightCall[T any] struct {
+		done  chan struct{}
+		value T
+		err   error
+	}
+
+	// pool bounds the number of concurrent network operations
+	pool chan struct{}
+)
+
+func newFlight[T any]() *flight[T] {
+	return &flight[T]{calls: make(map[string]*flightCall[T])}
+}
+
+func (f *flight[T]) Do(key string, fn func() (T, error)) (T, error) {
+	f.mu.Lock()
+	if c, ok := f.calls[key]; ok {
+		f.mu.Unlock()
+		<-c.done
+		return c.value, c.err
+	}
+	c := &flightCall[T]{done: make(chan struct{})}
+	f.calls[key] = c
+	f.mu.Unlock()
+
+	c.value, c.err = fn()
+	close(c.done)
+
+	return c.value, c.err
+}
+
+func newPool(size int) pool {
+	if size < 1 {
+		size = 1
+	}
+	return make(pool, size)
+}
+
+func (p pool) acquire() {
+	p <- struct{}{}
+}
+
+func (p pool) release() {
+	<-p
+}
+
+// joinErrors joins errors collected from concurrent workers in a stable order,
+// missing packages are reported together as a single list
+func joinErrors(errs []error) error {
+	var missing []string
+	var other []error
+
+	for _, err := range errs {
+		var missingErr *MissingPackageError
+		if errors.As(err, &missingErr) {
+			missing = append(missing, missingErr.Uri)
+		} else {
+			other = append(other, err)
+		}
+	}
+
+	errs = other
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		errs = append(errs, fmt.Errorf("missing packages:\n  %s", strings.Join(missing, "\n  ")))
+	}
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Error() < errs[j].Error()
+	})
+	return errors.Join(errs...)
+}
@@ -1,6 +1,9 @@
 package app
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,7 +12,9 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/Masterminds/semver/v3"
 	"hpkl.io/hpkl/pkg/pklutils"
@@ -17,8 +22,6 @@ import (
 )
 
 type (
-	ResolverType int
-
 	Checksums struct {
 		Sha256 string `json:"sha256"`
 	}
@@ -37,32 +40,51 @@ type (
 		PackageZipUrl       string                `json:"packageZipUrl"`
 		PackageZipChecksums Checksums             `json:"packageZipChecksums"`
 		Authors             []string              `json:"authors"`
+		License             string                `json:"license,omitempty"`
 		Dependencies        map[string]Dependency `json:"dependencies"`
-		ResolverType        ResolverType          `json:"-"`
-		PlainHttp           bool                  `json:"-"`
+		Source              *Source               `json:"-"`
 	}
 
 	Resolver struct {
-		ociResolver  *OciResolver
-		httpResolver *HttpResolver
-		basePath     string
-		cache        map[string]*Metadata
-		config       *AppConfig
+		sources  *Sources
+		basePath string
+		cache    *flight[*Metadata]
+		versions *flight[[]string]
+		metadata *MetadataCache
+		admit    *admission
+		pool     pool
+		config   *AppConfig
+
+		mu           sync.Mutex
+		requirements map[string][]requirement
+	}
+
+	// requirement is a dependency edge as declared by a package or the project
+	requirement struct {
+		From     string
+		Uri      string
+		Resolved string
+	}
+
+	PackageIndex struct {
+		Versions []string `json:"versions"`
 	}
 
 	DependencyResolver interface {
-		ResolveMetadata(uri string, plainHttp bool) (*Metadata, error)
-		ResolveArchive(metadata *Metadata) ([]byte, error)
+		ResolveMetadata(ctx context.Context, uri string) (*Metadata, error)
+		ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error)
+		ResolveVersions(ctx context.Context, uri string) ([]string, error)
 	}
 
 	OciResolver struct {
-		client      *registry.Client
-		plainClient *registry.Client
-		config      *AppConfig
+		client *registry.Client
+		config *AppConfig
 	}
 
 	HttpResolver struct {
 		config    *AppConfig
+		client    *http.Client
+		auth      *HttpAuth
 		plainHttp bool
 	}
 
@@ -79,30 +101,76 @@ type (
 )
 
 const (
-	OCI ResolverType = iota
-	HTTP
+	// StrategyHighest keeps the highest version required within a major version
+	StrategyHighest = "highest"
+	// StrategyMinimal keeps the lowest version satisfying all requirements and resolves ranges to their lowest match
+	StrategyMinimal = "minimal"
+
+	// projectRequirement is the origin of dependencies declared by the project itself
+	projectRequirement = "PklProject"
 )
 
 func NewResolver(appConfig *AppConfig) (*Resolver, error) {
-	oci, err := NewOciResolver(appConfig)
+	switch appConfig.Strategy {
+	case "", StrategyHighest, StrategyMinimal:
+	default:
+		return nil, fmt.Errorf("unknown version selection strategy %s, expected %s or %s", appConfig.Strategy, StrategyHighest, StrategyMinimal)
+	}
+
+	basePath := PackageCacheDir(appConfig.CacheDir)
+
+	var sources *Sources
+
+	if appConfig.Offline {
+		sources = NewCacheSources(basePath)
+	} else {
+		config, err := appConfig.Config()
 
+		if err != nil {
+			return nil, err
+		}
+
+		sources, err = NewSources(appConfig, config)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	policy, err := appConfig.Policy()
 	if err != nil {
 		return nil, err
 	}
 
-	http := NewHttpResolver(appConfig)
-
+	admit, err := newAdmission(policy)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Resolver{
-		ociResolver:  oci,
-		httpResolver: http,
-		basePath:     filepath.Join(appConfig.CacheDir, "package-2"),
+	resolver := &Resolver{
+		sources:      sources,
+		admit:        admit,
+		basePath:     basePath,
 		config:       appConfig,
-		cache:        make(map[string]*Metadata),
-	}, nil
+		cache:        newFlight[*Metadata](),
+		versions:     newFlight[[]string](),
+		pool:         newPool(appConfig.Concurrency),
+		requirements: make(map[string][]requirement),
+	}
+
+	if !appConfig.Offline && !appConfig.NoMetadataCache {
+		resolver.metadata = NewMetadataCache(appConfig.CacheDir, appConfig.MetadataTtl, appConfig.Logger)
+	}
+
+	return resolver, nil
+}
+
+// requestContext limits a single network request with AppConfig.RequestTimeout
+func (r *Resolver) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.config.RequestTimeout > 0 {
+		return context.WithTimeout(ctx, r.config.RequestTimeout)
+	}
+	return context.WithCancel(ctx)
 }
 
 func (r *Resolver) MajorVersionPackage(metadata *Metadata) (string, error) {
@@ -125,99 +193,376 @@ func (r *Resolver) MajorVersionPackage(metadata *Metadata) (string, error) {
 
 }
 
+// Deduplicate keeps a single version per package major version, chosen according to AppConfig.Strategy
 func (r *Resolver) Deduplicate(dependecies map[string]*Metadata) (map[string]*Metadata, error) {
-	versioned := make(map[string]*Metadata)
+	versioned := make(map[string][]*Metadata)
 
 	for _, dep := range dependecies {
 		depVersion, err := r.MajorVersionPackage(dep)
-		if err == nil {
-			exists, ok := versioned[depVersion]
-			if !ok {
-				versioned[depVersion] = dep
-			} else {
-				verDep := semver.MustParse(dep.Version)
-				verExists := semver.MustParse(exists.Version)
-
-				if verDep.GreaterThan(verExists) {
-					versioned[depVersion] = dep
-				}
-			}
-		} else {
+		if err != nil {
 			return nil, err
 		}
+		versioned[depVersion] = append(versioned[depVersion], dep)
 	}
 
+	keys := make([]string, 0, len(versioned))
+	for key := range versioned {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
 	result := make(map[string]*Metadata)
-	for _, dep := range versioned {
-		result[dep.PackageUri] = dep
+	var errs []error
+
+	for _, key := range keys {
+		var selected *Metadata
+		var err error
+
+		if r.config.Strategy == StrategyMinimal {
+			selected, err = r.selectMinimal(key, versioned[key])
+		} else {
+			selected = selectHighest(versioned[key])
+		}
+
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		r.warnReplaced(key, selected)
+
+		result[selected.PackageUri] = selected
+	}
+
+	if len(errs) > 0 {
+		return nil, joinErrors(errs)
 	}
 
 	return result, nil
 }
 
-func (r *Resolver) Resolve(dependencies map[string]Dependency) (map[string]*Metadata, error) {
+func selectHighest(candidates []*Metadata) *Metadata {
+	selected := candidates[0]
+
+	for _, dep := range candidates[1:] {
+		if semver.MustParse(dep.Version).GreaterThan(semver.MustParse(selected.Version)) {
+			selected = dep
+		}
+	}
+
+	return selected
+}
+
+// selectMinimal picks the lowest candidate satisfying every recorded requirement of the major version,
+// without recorded requirements each candidate version is a minimum requirement
+func (r *Resolver) selectMinimal(key string, candidates []*Metadata) (*Metadata, error) {
+	requirements := r.requirementsOf(key)
+
+	var required []string
+	for _, req := range requirements {
+		_, version := pklutils.PklSplitVersion(req.Uri)
+		required = append(required, version)
+	}
+	if len(required) == 0 {
+		for _, dep := range candidates {
+			required = append(required, dep.Version)
+		}
+	}
+
+	var selected *Metadata
+	var selectedVersion *semver.Version
+
+	for _, dep := range candidates {
+		version := semver.MustParse(dep.Version)
+
+		if selected != nil && !version.LessThan(selectedVersion) {
+			continue
+		}
+
+		matches := true
+		for _, req := range required {
+			if !satisfies(version, req) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			selected, selectedVersion = dep, version
+		}
+	}
+
+	if selected == nil {
+		var declared []string
+		for _, req := range requirements {
+			declared = append(declared, fmt.Sprintf("%s requires %s", req.From, req.Uri))
+		}
+		return nil, fmt.Errorf("no version of %s satisfies all requirements: %s", key, strings.Join(declared, ", "))
+	}
+
+	return selected, nil
+}
+
+// warnReplaced reports dependencies which get a different version than they resolved on their own
+func (r *Resolver) warnReplaced(key string, selected *Metadata) {
+	for _, req := range r.requirementsOf(key) {
+		if req.Resolved != selected.Version {
+			r.config.Logger.Warn("%s requires %s, using %s", req.From, req.Uri, selected.Version)
+		}
+	}
+}
+
+func (r *Resolver) requirementsOf(key string) []requirement {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	requirements := append([]requirement(nil), r.requirements[key]...)
+	sort.Slice(requirements, func(i, j int) bool {
+		if requirements[i].From != requirements[j].From {
+			return requirements[i].From < requirements[j].From
+		}
+		return requirements[i].Uri < requirements[j].Uri
+	})
+
+	return requirements
+}
+
+// require records a dependency edge for Deduplicate
+func (r *Resolver) require(from string, dependency Dependency, metadata *Metadata) {
+	key, err := r.MajorVersionPackage(metadata)
+
+	if err != nil {
+		return
+	}
+
+	req := requirement{From: from, Uri: dependency.Uri, Resolved: metadata.Version}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.requirements[key] {
+		if existing == req {
+			return
+		}
+	}
+
+	r.requirements[key] = append(r.requirements[key], req)
+}
+
+// sourceVersions lists versions of a versionless package uri once per resolver
+func (r *Resolver) sourceVersions(ctx context.Context, source *Source, baseUri string) ([]string, error) {
+	return r.versions.Do(baseUri, func() ([]string, error) {
+		r.pool.acquire()
+		defer r.pool.release()
+
+		ctx, cancel := r.requestContext(ctx)
+		defer cancel()
+
+		var versions []string
+		var err error
+
+		if r.metadata != nil && cacheableSource(source) {
+			versions, err = r.metadata.Versions(ctx, source.Resolver, baseUri)
+		} else {
+			versions, err = source.Resolver.ResolveVersions(ctx, baseUri)
+		}
+
+		if err != nil {
+			r.config.Logger.Error("Versions resolving error: %s", baseUri)
+		}
+
+		return versions, err
+	})
+}
+
+// DependencyVersions lists available versions of a declared dependency
+func (r *Resolver) DependencyVersions(ctx context.Context, dependency Dependency) ([]string, error) {
+	baseUri, _ := pklutils.PklSplitVersion(dependency.Uri)
+
+	return r.sourceVersions(ctx, r.sources.For(dependency), baseUri)
+}
+
+func (r *Resolver) ResolveVersion(ctx context.Context, source *Source, uri string) (string, error) {
+	logger := r.config.Logger
+	baseUri, version := pklutils.PklSplitVersion(uri)
+
+	if !IsVersionConstraint(version) {
+		return uri, nil
+	}
+
+	versions, err := r.sourceVersions(ctx, source, baseUri)
+
+	if err != nil {
+		return "", err
+	}
+
+	selectVersion := SelectVersion
+	if r.config.Strategy == StrategyMinimal {
+		selectVersion = SelectMinimalVersion
+	}
+
+	admitted := r.admit.admittedVersions(baseUri, versions)
+
+	selected, err := selectVersion(admitted, version)
+
+	if err != nil {
+		if _, rejected := selectVersion(versions, version); rejected == nil {
+			return "", &PolicyViolationError{Uri: uri, Reason: "every version satisfying the constraint is rejected"}
+		}
+		return "", fmt.Errorf("%s: %w", uri, err)
+	}
+
+	logger.Info("Resolved version constraint %s to %s", uri, selected)
+
+	return fmt.Sprintf("%s@%s", baseUri, selected), nil
+}
+
+func (r *Resolver) resolveDependency(ctx context.Context, dependency Dependency) (string, *Metadata, error) {
 	logger := r.config.Logger
+	dependencyName := dependency.Name
+
+	source := r.sources.For(dependency)
+
+	if err := r.admit.checkSource(source, dependency.Uri); err != nil {
+		return "", nil, err
+	}
+
+	uri, err := r.ResolveVersion(ctx, source, dependency.Uri)
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	if err := r.admit.checkVersion(uri); err != nil {
+		return "", nil, err
+	}
+
+	dependency.Uri = uri
+
+	metadata, err := r.cache.Do(uri, func() (*Metadata, error) {
+		r.pool.acquire()
+		defer r.pool.release()
+
+		logger.Info("Resolving: %s as %+v proto: %s", dependencyName, dependency, source.Type)
+
+		ctx, cancel := r.requestContext(ctx)
+		defer cancel()
+
+		var metadata *Metadata
+		var err error
+
+		if r.metadata != nil && cacheableSource(source) {
+			metadata, err = r.metadata.Metadata(ctx, source.Resolver, uri)
+		} else {
+			metadata, err = source.Resolver.ResolveMetadata(ctx, uri)
+		}
+
+		if err != nil {
+			logger.Error("Metadata resolving error: %s - %+v", dependencyName, dependency)
+			return nil, err
+		}
+
+		for metadataName, metadataDep := range metadata.Dependencies {
+			metadataDep.Name = metadataName
+			metadata.Dependencies[metadataName] = metadataDep
+		}
+
+		metadata.Source = source
+
+		return metadata, nil
+	})
+
+	if err == nil {
+		err = r.admit.checkArchive(source, metadata)
+	}
+
+	return uri, metadata, err
+}
+
+// Resolve walks the dependency graph concurrently, the number of parallel requests is bounded by AppConfig.Concurrency
+func (r *Resolver) Resolve(ctx context.Context, dependencies map[string]Dependency) (map[string]*Metadata, error) {
 	result := make(map[string]*Metadata)
 
-	for _, dependency := range dependencies {
-		metadata, ok := r.cache[dependency.Uri]
-		dependencyName := dependency.Name
-		if !ok {
-			var resolver DependencyResolver
-
-			if strings.HasSuffix(dependencyName, ".oci") {
-				logger.Info("Resolving: %s as %+v proto: oci", dependencyName, dependency)
-				resolver = r.ociResolver
-			} else {
-				logger.Info("Resolving: %s as %+v proto: http", dependencyName, dependency)
-				resolver = r.httpResolver
-			}
+	// parents are the packages depending on a package, policy violations are reported with every path to them
+	parents := make(map[string][]string)
+	violations := make(map[string]*PolicyViolationError)
 
-			plain := strings.Contains(dependencyName, ".plain")
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
 
-			metadata, err := resolver.ResolveMetadata(dependency.Uri, plain)
+	var visit func(from string, dependency Dependency)
+	visit = func(from string, dependency Dependency) {
+		defer wg.Done()
 
-			if err != nil {
-				logger.Error("Metadata resolving error: %s - %+v", dependencyName, dependency)
-				return nil, err
-			}
+		uri, metadata, err := r.resolveDependency(ctx, dependency)
+
+		mu.Lock()
+		defer mu.Unlock()
 
-			for metadataName, metadataDep := range metadata.Dependencies {
-				metadataDep.Name = metadataName
-				metadata.Dependencies[metadataName] = metadataDep
+		if err != nil {
+			var violation *PolicyViolationError
+			if !errors.As(err, &violation) {
+				errs = append(errs, err)
+				return
+			}
+			if _, ok := violations[violation.Uri]; !ok {
+				violations[violation.Uri] = &PolicyViolationError{Uri: violation.Uri, Reason: violation.Reason}
 			}
+			parents[violation.Uri] = appendParent(parents[violation.Uri], from)
+			return
+		}
 
-			r.cache[dependency.Uri] = metadata
-			result[dependency.Uri] = metadata
+		r.require(from, dependency, metadata)
 
-			if len(metadata.Dependencies) > 0 {
-				subs, err := r.Resolve(metadata.Dependencies)
+		parents[uri] = appendParent(parents[uri], from)
 
-				if err != nil {
-					return nil, err
-				}
+		if _, ok := result[uri]; ok {
+			return
+		}
 
-				for u, d := range subs {
-					result[u] = d
-				}
-			}
-		} else {
-			result[dependency.Uri] = metadata
+		result[uri] = metadata
+
+		for _, sub := range metadata.Dependencies {
+			wg.Add(1)
+			go visit(metadata.PackageUri, sub)
 		}
 	}
+
+	for _, dependency := range dependencies {
+		wg.Add(1)
+		go visit(projectRequirement, dependency)
+	}
+
+	wg.Wait()
+
+	rejected := make([]string, 0, len(violations))
+	for uri := range violations {
+		rejected = append(rejected, uri)
+	}
+	sort.Strings(rejected)
+
+	for _, uri := range rejected {
+		violations[uri].Paths = requirementPaths(parents, uri)
+		errs = append(errs, violations[uri])
+	}
+
+	if len(errs) > 0 {
+		return nil, joinErrors(errs)
+	}
+
 	return result, nil
 }
 
+// Exists checks the package archive, which is written to the cache last
 func (r *Resolver) Exists(metadata *Metadata) (bool, error) {
-	baseUri, err := url.Parse(metadata.PackageUri)
+	_, _, archivePath, err := r.packagePaths(metadata)
 
 	if err != nil {
 		return false, err
 	}
 
-	basePath := pklutils.PklGetRelativePath(r.basePath, baseUri)
-
-	if _, err := os.Stat(basePath); errors.Is(err, os.ErrNotExist) {
+	if _, err := os.Stat(archivePath); errors.Is(err, os.ErrNotExist) {
 		return false, nil
 	} else {
 		return true, nil
@@ -225,117 +570,232 @@ func (r *Resolver) Exists(metadata *Metadata) (bool, error) {
 
 }
 
-func (r *Resolver) Download(dependencies map[string]*Metadata) error {
+func (r *Resolver) packagePaths(metadata *Metadata) (string, string, string, error) {
+	baseUri, err := url.Parse(metadata.PackageUri)
 
-	logger := r.config.Logger
+	if err != nil {
+		return "", "", "", err
+	}
+
+	basePath := pklutils.PklGetRelativePath(r.basePath, baseUri)
+	metaPath := filepath.Join(basePath, fmt.Sprintf("%s@%s.json", metadata.Name, metadata.Version))
+	archivePath := filepath.Join(basePath, fmt.Sprintf("%s@%s.zip", metadata.Name, metadata.Version))
+
+	return basePath, metaPath, archivePath, nil
+}
+
+// VerifyCached re-checks already cached package archive against the metadata checksum
+func (r *Resolver) VerifyCached(metadata *Metadata) error {
+	_, _, archivePath, err := r.packagePaths(metadata)
+
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(archivePath)
+
+	if err != nil {
+		return err
+	}
+
+	defer file.Close()
+
+	actual, err := Sha256Reader(file)
+
+	if err != nil {
+		return err
+	}
+
+	return verifySha256(metadata, actual)
+}
+
+// Download fetches missing package archives into the cache using the same bounded concurrency as Resolve
+func (r *Resolver) Download(ctx context.Context, dependencies map[string]*Metadata) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var errs []error
 
 	for u, m := range dependencies {
-		e, err := r.Exists(m)
+		wg.Add(1)
 
-		if err != nil {
-			return err
-		}
+		go func(u string, m *Metadata) {
+			defer wg.Done()
 
-		if !e {
-			var resolver DependencyResolver
+			r.pool.acquire()
+			defer r.pool.release()
 
-			if m.ResolverType == OCI {
-				logger.Info("Downloading %s as %+v proto: oci", u, m)
-				resolver = r.ociResolver
-			} else {
-				logger.Info("Downloading %s as %+v proto: http", u, m)
-				resolver = r.httpResolver
+			if err := r.download(ctx, u, m); err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
 			}
+		}(u, m)
+	}
 
-			bytes, err := resolver.ResolveArchive(m)
+	wg.Wait()
 
-			if err != nil {
-				return err
-			}
+	if len(errs) > 0 {
+		return joinErrors(errs)
+	}
 
-			baseUri, err := url.Parse(u)
+	return nil
+}
 
-			if err != nil {
-				return err
-			}
+func (r *Resolver) download(ctx context.Context, u string, m *Metadata) error {
+	logger := r.config.Logger
 
-			basePath := pklutils.PklGetRelativePath(r.basePath, baseUri)
-			err = os.MkdirAll(basePath, os.ModePerm)
+	e, err := r.Exists(m)
 
-			if err != nil {
-				return err
-			}
+	if err != nil {
+		return err
+	}
 
-			metaPath := filepath.Join(basePath, fmt.Sprintf("%s@%s.json", m.Name, m.Version))
-			archivePath := filepath.Join(basePath, fmt.Sprintf("%s@%s.zip", m.Name, m.Version))
+	if e && r.config.VerifyCache {
+		logger.Info("Verifying cached %s", u)
 
-			metadataBytes, err := json.Marshal(m)
+		err = r.VerifyCached(m)
 
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
+		}
+	}
 
-			err = os.WriteFile(metaPath, metadataBytes, os.ModePerm)
+	if !e {
+		// metadata read from the metadata cache or built by callers does not always carry its source
+		if m.Source == nil || m.Source.Resolver == nil {
+			return fmt.Errorf("no source for %s", u)
+		}
 
-			if err != nil {
-				return err
-			}
+		logger.Info("Downloading %s as %+v proto: %s", u, m, m.Source.Type)
 
-			err = os.WriteFile(archivePath, bytes, os.ModePerm)
+		basePath, metaPath, archivePath, err := r.packagePaths(m)
 
-			if err != nil {
-				return err
-			}
+		if err != nil {
+			return err
+		}
+
+		// concurrent hpkl processes sharing the cache serialize writes of a package
+		lock, err := lockDir(ctx, basePath)
+
+		if err != nil {
+			return err
 		}
+
+		if err := r.fetch(ctx, m, metaPath, archivePath); err != nil {
+			// a failed or interrupted download leaves neither the lock file nor an empty package directory behind
+			lock.Remove()
+			return err
+		}
+
+		lock.Unlock()
 	}
 
 	return nil
 }
 
-func NewOciResolver(appConfig *AppConfig) (*OciResolver, error) {
-	var client, err = registry.NewClient(registry.WithPlainHttp(appConfig.PlainHttp))
+// fetch writes archive and metadata of the package into the cache unless another process did meanwhile
+func (r *Resolver) fetch(ctx context.Context, m *Metadata, metaPath string, archivePath string) error {
+	if e, err := r.Exists(m); err != nil || e {
+		return err
+	}
+
+	requestCtx, cancel := r.requestContext(ctx)
+	defer cancel()
+
+	archive, err := m.Source.Resolver.ResolveArchive(requestCtx, m)
+
+	if err != nil {
+		return err
+	}
+
+	defer archive.Close()
+
+	metadataBytes, err := json.Marshal(m)
+
+	if err != nil {
+		return err
+	}
+
+	// the archive is hashed while streamed to a temporary file and only renamed into place once verified
+	return writeAtomic(archivePath, func(w io.Writer) error {
+		hash := sha256.New()
+
+		if _, err := io.Copy(io.MultiWriter(w, hash), archive); err != nil {
+			return err
+		}
+
+		// do not touch the cache when the command was interrupted
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := verifySha256(m, hex.EncodeToString(hash.Sum(nil))); err != nil {
+			return err
+		}
+
+		// metadata goes first, Exists checks the archive
+		return writeFileAtomic(metaPath, metadataBytes)
+	})
+}
+
+func NewOciResolver(appConfig *AppConfig, plainHttp bool) (*OciResolver, error) {
+	httpClient, err := appConfig.HttpClient()
 	if err != nil {
 		return nil, err
 	}
 
-	plainClient, err := registry.NewClient(registry.WithPlainHttp(true))
-
+	client, err := registry.NewClient(
+		registry.WithPlainHttp(plainHttp),
+		registry.ClientOptHTTPClient(httpClient),
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	return &OciResolver{client: client, plainClient: plainClient, config: appConfig}, nil
+	return &OciResolver{client: client, config: appConfig}, nil
 }
 
-func (r *OciResolver) ResolveMetadata(uri string, plainHttp bool) (*Metadata, error) {
+func (r *OciResolver) ResolveMetadata(ctx context.Context, uri string) (*Metadata, error) {
+	metadata, _, err := r.RevalidateMetadata(ctx, uri, "")
+	return metadata, err
+}
+
+// RevalidateMetadata uses the manifest digest as validator, an unchanged digest costs a single manifest request
+func (r *OciResolver) RevalidateMetadata(ctx context.Context, uri string, digest string) (*Metadata, string, error) {
 	ref, err := pklutils.PklUriToRef(uri)
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	client := r.client
-	if plainHttp {
-		client = r.plainClient
+
+	if digest != "" {
+		if current, err := client.Resolve(ctx, ref); err == nil && current == digest {
+			return nil, digest, nil
+		}
 	}
 
-	result, err := client.Pull(ref, registry.PullOptWithPackage(false))
+	result, err := client.Pull(ctx, ref, registry.PullOptWithPackage(false))
 
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	var metadata *Metadata
 	if err := json.Unmarshal(result.Metadata.Data, &metadata); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	metadata.ResolverType = OCI
+	// the config blob holds the published project, its license fills in metadata that lacks one
+	if project := result.Archive.Project; metadata.License == "" && project != nil && project.Package != nil {
+		metadata.License = project.Package.License
+	}
 
-	return metadata, nil
+	return metadata, result.Manifest.Digest, nil
 }
 
-func (r *OciResolver) ResolveArchive(metadata *Metadata) ([]byte, error) {
+func (r *OciResolver) ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error) {
 	ref, err := pklutils.PklUriToRef(metadata.PackageUri)
 
 	if err != nil {
@@ -343,82 +803,211 @@ func (r *OciResolver) ResolveArchive(metadata *Metadata) ([]byte, error) {
 	}
 
 	client := r.client
-	if metadata.PlainHttp {
-		client = r.plainClient
-	}
 
-	result, err := client.Pull(ref, registry.PullOptWithPackage(true))
+	result, err := client.Pull(ctx, ref, registry.PullOptWithPackage(true))
 
 	if err != nil {
 		return nil, err
 	}
 
-	return result.Archive.Data, nil
+	return newDigestReader(result.Archive.Reader, metadata.PackageUri, result.Archive.Digest), nil
 }
 
-func NewHttpResolver(appConfig *AppConfig) *HttpResolver {
-	return &HttpResolver{plainHttp: appConfig.PlainHttp, config: appConfig}
+func (r *OciResolver) ResolveVersions(ctx context.Context, uri string) ([]string, error) {
+	ref, err := pklutils.PklUriToRepository(uri)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return r.client.Tags(ctx, ref)
 }
 
-func (r *HttpResolver) ResolveMetadata(uri string, plainHttp bool) (*Metadata, error) {
+// NewHttpResolver creates the http client and loads credentials up front,
+// the resolver is used by concurrent resolve goroutines
+func NewHttpResolver(appConfig *AppConfig, plainHttp bool) (*HttpResolver, error) {
+	client, err := appConfig.HttpClient()
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := appConfig.HttpAuth()
+	if err != nil {
+		return nil, err
+	}
+
+	return &HttpResolver{plainHttp: plainHttp, config: appConfig, client: client, auth: auth}, nil
+}
 
+func (r *HttpResolver) httpUrl(uri string) (*url.URL, error) {
 	u, err := url.Parse(uri)
-	logger := r.config.Logger
 
 	if err != nil {
-		logger.Error("Parsing error %s", uri)
+		r.config.Logger.Error("Parsing error %s", uri)
 		return nil, err
 	}
 
-	if r.plainHttp || plainHttp {
+	if r.plainHttp {
 		u.Scheme = "http"
 	} else {
 		u.Scheme = "https"
 	}
 
-	resp, err := http.Get(u.String())
+	return u, nil
+}
+
+func (r *HttpResolver) get(ctx context.Context, u string) ([]byte, error) {
+	body, _, _, err := r.getConditional(ctx, u, "")
+	return body, err
+}
+
+// request sends credentials of the host of u, only over https and never those of another host
+func (r *HttpResolver) request(ctx context.Context, u string, etag string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
 
 	if err != nil {
-		logger.Error("Http get error %s", u.String())
 		return nil, err
 	}
 
-	if resp.StatusCode > 300 {
-		return nil, fmt.Errorf("Http get Error status: %s", resp.Status)
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+
+	auth := r.auth
+	client := r.client
+
+	var credentials *HttpAuthConfig
+	if req.URL.Scheme == "https" {
+		credentials = auth.For(req.URL.Host)
+	}
+
+	if credentials != nil {
+		credentials.apply(req)
+
+		// custom headers must not follow redirects to other hosts or to plain http, authorization is dropped by net/http itself
+		if len(credentials.Headers) > 0 {
+			redirecting := *client
+			redirecting.CheckRedirect = func(redirect *http.Request, via []*http.Request) error {
+				if len(via) >= 10 {
+					return errors.New("stopped after 10 redirects")
+				}
+				if redirect.URL.Host != req.URL.Host || redirect.URL.Scheme != "https" {
+					for name := range credentials.Headers {
+						redirect.Header.Del(name)
+					}
+				}
+				return nil
+			}
+			client = &redirecting
+		}
+	}
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		r.config.Logger.Error("Http get error %s", u)
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// getConditional sends If-None-Match with a previously seen etag, notModified is set when the server answers 304
+func (r *HttpResolver) getConditional(ctx context.Context, u string, etag string) (body []byte, newEtag string, notModified bool, err error) {
+	resp, err := r.request(ctx, u, etag)
+
+	if err != nil {
+		return nil, "", false, err
 	}
 
 	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+
+	if etag != "" && resp.StatusCode == http.StatusNotModified {
+		return nil, etag, true, nil
+	}
+
+	if resp.StatusCode > 300 {
+		return nil, "", false, fmt.Errorf("Http get Error status: %s", resp.Status)
+	}
+
+	body, err = io.ReadAll(resp.Body)
+
+	return body, resp.Header.Get("ETag"), false, err
+}
+
+// open streams the body of u, the caller must close it
+func (r *HttpResolver) open(ctx context.Context, u string) (io.ReadCloser, error) {
+	resp, err := r.request(ctx, u, "")
+
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.StatusCode > 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Http get Error status: %s", resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+func (r *HttpResolver) ResolveMetadata(ctx context.Context, uri string) (*Metadata, error) {
+	metadata, _, err := r.RevalidateMetadata(ctx, uri, "")
+	return metadata, err
+}
+
+// RevalidateMetadata uses the ETag of the metadata document as validator
+func (r *HttpResolver) RevalidateMetadata(ctx context.Context, uri string, etag string) (*Metadata, string, error) {
+
+	u, err := r.httpUrl(uri)
+	logger := r.config.Logger
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	body, etag, notModified, err := r.getConditional(ctx, u.String(), etag)
+
+	if err != nil || notModified {
+		return nil, etag, err
+	}
+
 	var metadata *Metadata
 	if err := json.Unmarshal(body, &metadata); err != nil {
 		logger.Error("Json unmarshal error: %s", body)
-		return nil, err
+		return nil, "", err
 	}
 
-	metadata.ResolverType = HTTP
-	metadata.PlainHttp = plainHttp
+	return metadata, etag, nil
+}
 
-	return metadata, nil
+func (r *HttpResolver) ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error) {
+	return r.open(ctx, metadata.PackageZipUrl)
 }
 
-func (r *HttpResolver) ResolveArchive(metadata *Metadata) ([]byte, error) {
-	var err error
-	resp, err := http.Get(metadata.PackageZipUrl)
+// ResolveVersions reads the package index served next to the package metadata,
+// e.g. https://host/path/index.json for package://host/path@1.0.0
+func (r *HttpResolver) ResolveVersions(ctx context.Context, uri string) ([]string, error) {
+	u, err := r.httpUrl(uri)
+	logger := r.config.Logger
 
 	if err != nil {
 		return nil, err
 	}
 
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	u.Path += "/index.json"
+
+	body, err := r.get(ctx, u.String())
 
 	if err != nil {
 		return nil, err
 	}
 
-	return body, nil
+	var index PackageIndex
+	if err := json.Unmarshal(body, &index); err != nil {
+		logger.Error("Json unmarshal error: %s", body)
+		return nil, err
+	}
+
+	return index.Versions, nil
 }
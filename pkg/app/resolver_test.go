@@ -1,15 +1,34 @@
 package app
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/apple/pkl-go/pkl"
 	"github.com/google/go-cmp/cmp"
+	"hpkl.io/hpkl/pkg/httpclient"
 	"hpkl.io/hpkl/pkg/logger"
 )
 
 func TestDeduplicate(t *testing.T) {
+	isolateHome(t)
+
 	stdWriter := new(bytes.Buffer)
 	errWriter := new(bytes.Buffer)
 
@@ -45,3 +64,1213 @@ func TestDeduplicate(t *testing.T) {
 		t.Errorf(diff)
 	}
 }
+
+func TestSelectVersion(t *testing.T) {
+	versions := []string{"1.1.0", "1.2.0", "1.2.5", "1.4.1", "1.5.0-rc.1", "2.0.0"}
+
+	cases := map[string]string{
+		"^1.2":      "1.4.1",
+		"~1.2.0":    "1.2.5",
+		">=1.0 <2":  "1.4.1",
+		">=1.5.0-0": "2.0.0",
+	}
+
+	for constraint, expected := range cases {
+		actual, err := SelectVersion(versions, constraint)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != expected {
+			t.Errorf("%s: expected %s, got %s", constraint, expected, actual)
+		}
+	}
+
+	if _, err := SelectVersion(versions, "^3"); err == nil {
+		t.Errorf("expected error for unsatisfiable constraint")
+	}
+
+	if IsVersionConstraint("1.2.3") || !IsVersionConstraint("^1.2") {
+		t.Errorf("unexpected version constraint detection")
+	}
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	data := []byte("archive")
+	metadata := &Metadata{PackageUri: "package://host/path@1.0.0", PackageZipChecksums: Checksums{Sha256: Sha256(data)}}
+
+	if err := VerifyChecksum(metadata, data); err != nil {
+		t.Fatal(err)
+	}
+
+	err := VerifyChecksum(metadata, []byte("tampered"))
+
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("expected checksum error, got %v", err)
+	}
+
+	if checksumErr.Package != metadata.PackageUri || checksumErr.Expected != Sha256(data) {
+		t.Errorf("unexpected checksum error: %v", checksumErr)
+	}
+}
+
+func TestFlightDeduplicatesCalls(t *testing.T) {
+	f := newFlight[int]()
+
+	var calls atomic.Int32
+	var wg sync.WaitGroup
+
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := f.Do("key", func() (int, error) {
+				calls.Add(1)
+				return 42, nil
+			})
+			if v != 42 {
+				t.Errorf("expected 42, got %d", v)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if calls.Load() != 1 {
+		t.Errorf("expected a single call, got %d", calls.Load())
+	}
+}
+
+func TestGraphPaths(t *testing.T) {
+	k8s := &GraphNode{Name: "k8s", Uri: "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.1", Requested: "1.0.1", Resolved: "1.1.0", Source: "http"}
+	root := &GraphNode{Name: "test", Source: "project", Dependencies: []*GraphNode{
+		{Name: "a", Uri: "package://host/a@1.0.0", Requested: "1.0.0", Resolved: "1.0.0", Source: "oci", Dependencies: []*GraphNode{k8s}},
+		{Name: "k8s", Uri: "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.1.0", Requested: "1.1.0", Resolved: "1.1.0", Source: "http"},
+	}}
+
+	paths := GraphPaths(root, "pkl-k8s/k8s")
+
+	if len(paths) != 2 {
+		t.Fatalf("expected 2 paths, got %d", len(paths))
+	}
+
+	if len(GraphPaths(root, "pkl-k8s/k8s@1.0.1")) != 1 {
+		t.Errorf("expected a single path for exact version")
+	}
+
+	out := new(bytes.Buffer)
+	if err := WritePathsText(out, paths); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "test > a@1.0.0 > k8s@1.0.1 -> 1.1.0 (k8s upgraded from 1.0.1 to newer minor version 1.1.0)\ntest > k8s@1.1.0\n"
+	if diff := cmp.Diff(expected, out.String()); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+// localProjectGraph resolves a project depending on a directly and on b through the local project lib
+func localProjectGraph(t *testing.T) *GraphNode {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0"})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0"})
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: cacheDir,
+		Offline:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dependencies := &pkl.ProjectDependencies{
+		LocalDependencies: map[string]*pkl.ProjectLocalDependency{
+			"lib": {PackageUri: "package://host/lib@1.0.0", Dependencies: &pkl.ProjectDependencies{
+				RemoteDependencies: map[string]*pkl.ProjectRemoteDependency{"b": {PackageUri: "package://host/b@1.0.0"}},
+			}},
+		},
+		RemoteDependencies: map[string]*pkl.ProjectRemoteDependency{"a": {PackageUri: "package://host/a@1.0.0"}},
+	}
+
+	resolved, err := r.Resolve(context.Background(), map[string]Dependency{
+		"package://host/a@1.0.0": {Name: "a", Uri: "package://host/a@1.0.0"},
+		"package://host/b@1.0.0": {Name: "b", Uri: "package://host/b@1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deduplicated, err := r.Deduplicate(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := r.Graph(context.Background(), "test", dependencies, deduplicated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestGraphLocalProjects(t *testing.T) {
+	root := localProjectGraph(t)
+
+	out := new(bytes.Buffer)
+	if err := WritePathsText(out, GraphPaths(root, "b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff("test > lib@1.0.0 > b@1.0.0\n", out.String()); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestWriteGraph(t *testing.T) {
+	// a diamond: a and b both depend on c, which is upgraded by the direct dependency of the project
+	dependencyC := func() *GraphNode {
+		return &GraphNode{Name: "c", Uri: "package://host/c@1.0.0", Requested: "1.0.0", Resolved: "1.1.0", Source: "http", Dependencies: []*GraphNode{
+			{Name: "d", Uri: "package://host/d@1.0.0", Requested: "1.0.0", Resolved: "1.0.0", Source: "http"},
+		}}
+	}
+	root := &GraphNode{Name: "test", Source: "project", Dependencies: []*GraphNode{
+		{Name: "a", Uri: "package://host/a@1.0.0", Requested: "1.0.0", Resolved: "1.0.0", Source: "oci", Dependencies: []*GraphNode{dependencyC()}},
+		{Name: "b", Uri: "package://host/b@1.0.0", Requested: "1.0.0", Resolved: "1.0.0", Source: "oci", Dependencies: []*GraphNode{dependencyC()}},
+		{Name: "c", Uri: "package://host/c@1.1.0", Requested: "1.1.0", Resolved: "1.1.0", Source: "http"},
+	}}
+
+	cases := map[string]struct {
+		write    func(io.Writer, *GraphNode) error
+		expected string
+	}{
+		// repeated nodes are printed on every path in the tree
+		"text": {WriteGraphText, `test
+├── a@1.0.0 [oci] package://host/a@1.0.0
+│   └── c@1.0.0 -> 1.1.0 [http] package://host/c@1.0.0
+│       └── d@1.0.0 [http] package://host/d@1.0.0
+├── b@1.0.0 [oci] package://host/b@1.0.0
+│   └── c@1.0.0 -> 1.1.0 [http] package://host/c@1.0.0
+│       └── d@1.0.0 [http] package://host/d@1.0.0
+└── c@1.1.0 [http] package://host/c@1.1.0
+`},
+		// and numbered once in order of first appearance, with every edge written once
+		"dot": {WriteGraphDot, `digraph dependencies {
+  n0 [label="test"];
+  n1 [label="a@1.0.0\noci"];
+  n2 [label="c@1.0.0 -> 1.1.0\nhttp"];
+  n3 [label="d@1.0.0\nhttp"];
+  n4 [label="b@1.0.0\noci"];
+  n5 [label="c@1.1.0\nhttp"];
+  n0 -> n1;
+  n1 -> n2;
+  n2 -> n3;
+  n0 -> n4;
+  n4 -> n2;
+  n0 -> n5;
+}
+`},
+		"mermaid": {WriteGraphMermaid, `graph TD
+  n0["test"]
+  n1["a@1.0.0 (oci)"]
+  n2["c@1.0.0 -> 1.1.0 (http)"]
+  n3["d@1.0.0 (http)"]
+  n4["b@1.0.0 (oci)"]
+  n5["c@1.1.0 (http)"]
+  n0 --> n1
+  n1 --> n2
+  n2 --> n3
+  n0 --> n4
+  n4 --> n2
+  n0 --> n5
+`},
+	}
+
+	for name, c := range cases {
+		out := new(bytes.Buffer)
+		if err := c.write(out, root); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(c.expected, out.String()); diff != "" {
+			t.Errorf("%s: %s", name, diff)
+		}
+	}
+}
+
+func TestWriteGraphLocalProjects(t *testing.T) {
+	root := localProjectGraph(t)
+
+	cases := map[string]struct {
+		write    func(io.Writer, *GraphNode) error
+		expected string
+	}{
+		"text": {WriteGraphText, `test
+├── a@1.0.0 [cache] package://host/a@1.0.0
+└── lib@1.0.0 [local] package://host/lib@1.0.0
+    └── b@1.0.0 [cache] package://host/b@1.0.0
+`},
+		"dot": {WriteGraphDot, `digraph dependencies {
+  n0 [label="test"];
+  n1 [label="a@1.0.0\ncache"];
+  n2 [label="lib@1.0.0\nlocal"];
+  n3 [label="b@1.0.0\ncache"];
+  n0 -> n1;
+  n0 -> n2;
+  n2 -> n3;
+}
+`},
+	}
+
+	for name, c := range cases {
+		out := new(bytes.Buffer)
+		if err := c.write(out, root); err != nil {
+			t.Fatal(err)
+		}
+
+		if diff := cmp.Diff(c.expected, out.String()); diff != "" {
+			t.Errorf("%s: %s", name, diff)
+		}
+	}
+
+	out := new(bytes.Buffer)
+	if err := WriteGraphJson(out, root); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded GraphNode
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(decoded.Dependencies) != 2 || decoded.Dependencies[1].Name != "lib" || len(decoded.Dependencies[1].Dependencies) != 1 || decoded.Dependencies[1].Dependencies[0].Name != "b" {
+		t.Errorf("expected b as dependency of lib in json, got %s", out.String())
+	}
+}
+
+func TestSourcesFor(t *testing.T) {
+	isolateHome(t)
+
+	appConfig := &AppConfig{
+		Logger: logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:    context.Background(),
+	}
+
+	sources, err := NewSources(appConfig, &Config{
+		Sources: []*SourceConfig{
+			{Match: "*.internal", Type: SourceOci},
+			{Match: "package://files.example.com/team/*", Type: SourceDir, Path: t.TempDir()},
+		},
+		Rewrites: []*RewriteConfig{
+			{From: "package://pkg.pkl-lang.org/*", To: "oci://harbor.internal/pkl-mirror/*"},
+		},
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[Dependency]string{
+		{Name: "a", Uri: "package://zot.internal/a@1.0.0"}:                 SourceOci,
+		{Name: "b", Uri: "package://files.example.com/team/b@1.0.0"}:       SourceDir,
+		{Name: "c", Uri: "package://files.example.com/other@1.0.0"}:        SourceHttps,
+		{Name: "d.oci", Uri: "package://registry.example.com/d@1.0"}:       SourceOci,
+		{Name: "e.plain", Uri: "package://example.com/e@1.0.0"}:            SourceHttp,
+		{Name: "k8s", Uri: "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.1"}: SourceOci,
+	}
+
+	for dependency, expected := range cases {
+		if actual := sources.For(dependency).Type; actual != expected {
+			t.Errorf("%s: expected %s, got %s", dependency.Uri, expected, actual)
+		}
+	}
+}
+
+func TestDirResolver(t *testing.T) {
+	dir := t.TempDir()
+	archive := []byte("archive")
+
+	for _, version := range []string{"1.0.0", "1.1.0"} {
+		basePath := filepath.Join(dir, "host", "path@"+version)
+		metadata, _ := json.Marshal(Metadata{Name: "path", Version: version, PackageUri: "package://host/path@" + version})
+
+		if err := os.MkdirAll(basePath, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(basePath, "path@"+version+".json"), metadata, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(basePath, "path@"+version+".zip"), archive, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := &DirResolver{path: dir}
+
+	versions, err := r.ResolveVersions(context.Background(), "package://host/path")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"1.0.0", "1.1.0"}, versions); diff != "" {
+		t.Errorf(diff)
+	}
+
+	metadata, err := r.ResolveMetadata(context.Background(), "package://host/path@1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := r.ResolveArchive(context.Background(), metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(archive) {
+		t.Errorf("unexpected archive content %s", data)
+	}
+}
+
+// isolateHome keeps hpkl configuration and netrc of the developer running the tests out of them
+func isolateHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("NETRC", "")
+}
+
+func writeCachedPackage(t *testing.T, cacheDir string, metadata Metadata) {
+	basePath := filepath.Join(cacheDir, "package-2", "host", metadata.Name+"@"+metadata.Version)
+	data, _ := json.Marshal(metadata)
+
+	if err := os.MkdirAll(basePath, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(basePath, metadata.Name+"@"+metadata.Version+".json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOfflineResolve(t *testing.T) {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{
+		Name:       "a",
+		Version:    "1.0.0",
+		PackageUri: "package://host/a@1.0.0",
+		Dependencies: map[string]Dependency{
+			"b": {Uri: "package://host/b@1.0.0"},
+			"c": {Uri: "package://host/c@^1.0"},
+		},
+	})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "c", Version: "1.2.0", PackageUri: "package://host/c@1.2.0"})
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: cacheDir,
+		Offline:  true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = r.Resolve(context.Background(), map[string]Dependency{
+		"a": {Name: "a", Uri: "package://host/a@1.0.0"},
+		"d": {Name: "d", Uri: "package://host/d@2.0.0"},
+	})
+
+	expected := "missing packages:\n  package://host/b@1.0.0\n  package://host/d@2.0.0"
+
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected missing packages error, got %v", err)
+	}
+}
+
+func TestUpgradeCandidates(t *testing.T) {
+	versions := []string{"1.0.1", "1.0.3", "1.2.0", "1.3.0-rc.1", "2.1.0", "3.0.0-beta"}
+
+	patch, minor, major, err := UpgradeCandidates(versions, "1.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"1.0.3", "1.2.0", "2.1.0"}, []string{patch, minor, major}); diff != "" {
+		t.Errorf(diff)
+	}
+
+	patch, minor, major, err = UpgradeCandidates(versions, "2.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"2.1.0", "2.1.0", "2.1.0"}, []string{patch, minor, major}); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestRewriteApply(t *testing.T) {
+	rewrite, err := NewRewrite("package://pkg.pkl-lang.org/*", "oci://harbor.internal/pkl-mirror/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, ok := rewrite.Apply("package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.1")
+
+	if !ok || actual != "oci://harbor.internal/pkl-mirror/pkl-k8s/k8s@1.0.1" {
+		t.Errorf("unexpected rewrite result %s", actual)
+	}
+
+	if _, ok := rewrite.Apply("package://other.org/pkl-k8s/k8s@1.0.1"); ok {
+		t.Errorf("unexpected rewrite of other host")
+	}
+}
+
+func TestMetadataCache(t *testing.T) {
+	isolateHome(t)
+
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests[req.URL.Path]++
+		w.Header().Set("ETag", `"v1"`)
+		if req.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		fmt.Fprintf(w, `{"name": "a", "version": "x", "packageUri": "package://%s%s"}`, req.Host, req.URL.Path)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	config := &AppConfig{Logger: logger.New(new(bytes.Buffer), new(bytes.Buffer))}
+	resolver, err := NewHttpResolver(config, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := NewMetadataCache(t.TempDir(), 0, config.Logger)
+
+	for i := 0; i < 2; i++ {
+		for _, version := range []string{"1.0.0", "1.1.0-rc.1"} {
+			uri := fmt.Sprintf("package://%s/a@%s", host, version)
+			metadata, err := cache.Metadata(context.Background(), resolver, uri)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if metadata.PackageUri != uri {
+				t.Errorf("expected %s, got %s", uri, metadata.PackageUri)
+			}
+		}
+	}
+
+	if requests["/a@1.0.0"] != 1 {
+		t.Errorf("released version fetched %d times", requests["/a@1.0.0"])
+	}
+	if requests["/a@1.1.0-rc.1"] != 2 {
+		t.Errorf("prerelease version expected to be revalidated, fetched %d times", requests["/a@1.1.0-rc.1"])
+	}
+
+	// a cache directory which can't be written must not fail resolving
+	blocked := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(blocked, nil, 0644)
+
+	errWriter := new(bytes.Buffer)
+	unwritable := NewMetadataCache(blocked, 0, logger.New(new(bytes.Buffer), errWriter))
+
+	uri := fmt.Sprintf("package://%s/a@1.0.0", host)
+	if metadata, err := unwritable.Metadata(context.Background(), resolver, uri); err != nil || metadata.PackageUri != uri {
+		t.Errorf("expected metadata despite the cache write failure, got %v %v", metadata, err)
+	}
+	if !strings.Contains(errWriter.String(), "Warning: Could not cache metadata of "+uri) {
+		t.Errorf("expected a warning, got %q", errWriter.String())
+	}
+}
+
+func TestLockDir(t *testing.T) {
+	dir := t.TempDir()
+
+	lock, err := lockDir(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*lockRetryInterval)
+	defer cancel()
+
+	if _, err := lockDir(ctx, dir); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second lock to wait until the deadline, got %v", err)
+	}
+
+	if err := lock.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	lock, err = lockDir(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Unlock()
+
+	// a waiting process takes the lock on a new file once the holder removed the lock file and the directory
+	packageDir := filepath.Join(dir, "package")
+
+	lock, err = lockDir(context.Background(), packageDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waiting := make(chan *fileLock)
+	go func() {
+		lock, err := lockDir(context.Background(), packageDir)
+		if err != nil {
+			t.Error(err)
+		}
+		waiting <- lock
+	}()
+
+	time.Sleep(lockRetryInterval)
+
+	if err := lock.Remove(); err != nil {
+		t.Fatal(err)
+	}
+
+	if lock = <-waiting; lock == nil {
+		return
+	}
+	defer lock.Unlock()
+
+	if lockFileRemoved(lock.file, filepath.Join(packageDir, lockFileName)) {
+		t.Error("expected the lock to be taken on the recreated lock file")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	isolateHome(t)
+
+	sourceDir := t.TempDir()
+	cacheDir := t.TempDir()
+	archive := []byte("archive")
+
+	for _, name := range []string{"a", "b"} {
+		basePath := filepath.Join(sourceDir, "host", name+"@1.0.0")
+		if err := os.MkdirAll(basePath, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(basePath, name+"@1.0.0.zip"), archive, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: cacheDir,
+		Offline:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source := &Source{Type: SourceDir, Resolver: &DirResolver{path: sourceDir}}
+	good := &Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0", PackageZipChecksums: Checksums{Sha256: Sha256(archive)}, Source: source}
+	bad := &Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0", PackageZipChecksums: Checksums{Sha256: Sha256([]byte("other"))}, Source: source}
+
+	err = r.Download(context.Background(), map[string]*Metadata{good.PackageUri: good, bad.PackageUri: bad})
+
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) || checksumErr.Package != bad.PackageUri {
+		t.Fatalf("expected checksum error for %s, got %v", bad.PackageUri, err)
+	}
+
+	if exists, _ := r.Exists(good); !exists {
+		t.Errorf("expected %s to be cached", good.PackageUri)
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "package-2", "host", "b@1.0.0")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected no package directory left for %s, got %v", bad.PackageUri, err)
+	}
+
+	if _, err := os.Stat(filepath.Join(cacheDir, "package-2", "host", "a@1.0.0", lockFileName)); err != nil {
+		t.Errorf("expected the lock file of %s to be kept, got %v", good.PackageUri, err)
+	}
+
+	unknown := &Metadata{Name: "c", Version: "1.0.0", PackageUri: "package://host/c@1.0.0"}
+	if err := r.Download(context.Background(), map[string]*Metadata{unknown.PackageUri: unknown}); err == nil || err.Error() != "no source for package://host/c@1.0.0" {
+		t.Errorf("expected missing source error, got %v", err)
+	}
+}
+
+func TestCachedPackages(t *testing.T) {
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0"})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0"})
+	os.WriteFile(filepath.Join(PackageCacheDir(cacheDir), "host", "stray.json"), []byte("{}"), 0644)
+
+	packages, err := ListCachedPackages(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(packages) != 2 || packages[0].PackageUri != "package://host/a@1.0.0" || packages[0].Size == 0 {
+		t.Fatalf("unexpected packages %+v", packages)
+	}
+
+	referenced := map[string]bool{packageKey("projectpackage://host/b@1.0.0"): true}
+	if packages[0].Referenced(referenced) || !packages[1].Referenced(referenced) {
+		t.Errorf("expected only b to be referenced")
+	}
+
+	if err := packages[0].Remove(context.Background(), cacheDir); err != nil {
+		t.Fatal(err)
+	}
+
+	packages, _ = ListCachedPackages(cacheDir)
+	if len(packages) != 1 || packages[0].Name != "b" {
+		t.Errorf("expected only b to be left, got %+v", packages)
+	}
+}
+
+func TestDeduplicateStrategies(t *testing.T) {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0",
+		Dependencies: map[string]Dependency{"c": {Uri: "package://host/c@1.2.0"}}})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0",
+		Dependencies: map[string]Dependency{"c": {Uri: "package://host/c@^1.0"}}})
+	for _, version := range []string{"1.0.0", "1.2.0", "1.5.0"} {
+		writeCachedPackage(t, cacheDir, Metadata{Name: "c", Version: version, PackageUri: "package://host/c@" + version})
+	}
+
+	cases := map[string]struct {
+		version string
+		warning string
+	}{
+		StrategyHighest: {"1.5.0", "Warning: package://host/a@1.0.0 requires package://host/c@1.2.0, using 1.5.0\n"},
+		StrategyMinimal: {"1.2.0", "Warning: package://host/b@1.0.0 requires package://host/c@^1.0, using 1.2.0\n"},
+	}
+
+	for strategy, expected := range cases {
+		errWriter := new(bytes.Buffer)
+
+		r, err := NewResolver(&AppConfig{
+			Logger:   logger.New(new(bytes.Buffer), errWriter),
+			ctx:      context.Background(),
+			CacheDir: cacheDir,
+			Offline:  true,
+			Strategy: strategy,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resolved, err := r.Resolve(context.Background(), map[string]Dependency{
+			"a": {Name: "a", Uri: "package://host/a@1.0.0"},
+			"b": {Name: "b", Uri: "package://host/b@1.0.0"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		deduplicated, err := r.Deduplicate(resolved)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := deduplicated["package://host/c@"+expected.version]; !ok || len(deduplicated) != 3 {
+			t.Errorf("%s: expected c@%s, got %v", strategy, expected.version, deduplicated)
+		}
+
+		if errWriter.String() != expected.warning {
+			t.Errorf("%s: unexpected warnings %q", strategy, errWriter.String())
+		}
+	}
+}
+
+func writeZip(t *testing.T, path string, files map[string]string) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVendor(t *testing.T) {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+	vendorDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0",
+		Dependencies: map[string]Dependency{"b": {Uri: "package://host/b@^1.0"}}})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.1.0", PackageUri: "package://host/b@1.1.0"})
+	writeZip(t, filepath.Join(cacheDir, "package-2", "host", "a@1.0.0", "a@1.0.0.zip"), map[string]string{"a.pkl": "a = 1"})
+	writeZip(t, filepath.Join(cacheDir, "package-2", "host", "b@1.1.0", "b@1.1.0.zip"), map[string]string{"b.pkl": "b = 1"})
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: cacheDir,
+		Offline:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resolved, err := r.Resolve(context.Background(), map[string]Dependency{"a": {Name: "a", Uri: "package://host/a@1.0.0"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vendored, err := r.Vendor(resolved, vendorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := vendored["package://host/a@1.0.0"]
+	if data, _ := os.ReadFile(filepath.Join(target, "a.pkl")); string(data) != "a = 1" {
+		t.Errorf("unexpected extracted content %q", data)
+	}
+
+	project, _ := os.ReadFile(filepath.Join(target, "PklProject"))
+	if !strings.Contains(string(project), `["b"] { uri = "package://host/b@1.1.0" }`) || !strings.Contains(string(project), `baseUri = "package://host/a"`) {
+		t.Errorf("unexpected PklProject:\n%s", project)
+	}
+
+	projectDir := t.TempDir()
+	for _, dir := range []string{".", "./", "a/..", "../outside"} {
+		if _, err := VendorDir(projectDir, dir); err == nil {
+			t.Errorf("expected vendor directory %q to be refused", dir)
+		}
+	}
+
+	existing, _ := VendorDir(projectDir, "src")
+	os.MkdirAll(existing, 0755)
+	os.WriteFile(filepath.Join(existing, "main.pkl"), nil, 0644)
+	if err := ResetVendorDir(existing); err == nil {
+		t.Error("expected a directory not created by hpkl vendor to be refused")
+	}
+	if _, err := os.Stat(filepath.Join(existing, "main.pkl")); err != nil {
+		t.Errorf("existing directory was modified: %v", err)
+	}
+
+	previous, _ := VendorDir(projectDir, "vendor")
+	for i := 0; i < 2; i++ {
+		if err := ResetVendorDir(previous); err != nil {
+			t.Fatal(err)
+		}
+		os.WriteFile(filepath.Join(previous, "stale.pkl"), nil, 0644)
+	}
+	if entries, _ := os.ReadDir(previous); len(entries) != 2 {
+		t.Errorf("expected previous vendor directory to be emptied, got %d entries", len(entries))
+	}
+
+	unsafe := filepath.Join(t.TempDir(), "unsafe.zip")
+	writeZip(t, unsafe, map[string]string{"../escape.pkl": ""})
+
+	if err := extractZip(unsafe, t.TempDir()); err == nil || !strings.Contains(err.Error(), "unsafe path ../escape.pkl") {
+		t.Errorf("expected unsafe path error, got %v", err)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0",
+		Dependencies: map[string]Dependency{"c": {Uri: "package://host/c@1.1.0"}}})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0"})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "c", Version: "1.1.0", PackageUri: "package://host/c@1.1.0"})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "c", Version: "1.2.0", PackageUri: "package://host/c@1.2.0"})
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: cacheDir,
+		Offline:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := map[string]Dependency{"a": {Name: "a", Uri: "package://host/a@1.0.0"}}
+	second := map[string]Dependency{
+		"b": {Name: "b", Uri: "package://host/b@1.0.0"},
+		"c": {Name: "c", Uri: "package://host/c@1.2.0"},
+	}
+
+	all := make(map[string]Dependency)
+	maps.Copy(all, first)
+	maps.Copy(all, second)
+
+	resolved, err := r.Resolve(context.Background(), all)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deduplicated, err := r.Deduplicate(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		dependencies map[string]Dependency
+		expected     []string
+	}{
+		{first, []string{"package://host/a@1.0.0", "package://host/c@1.2.0"}},
+		{second, []string{"package://host/b@1.0.0", "package://host/c@1.2.0"}},
+	}
+
+	for _, c := range cases {
+		reachable, err := r.Reachable(c.dependencies, deduplicated)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var actual []string
+		for uri := range reachable {
+			actual = append(actual, uri)
+		}
+		sort.Strings(actual)
+		if diff := cmp.Diff(c.expected, actual); diff != "" {
+			t.Errorf(diff)
+		}
+	}
+}
+
+func TestSbom(t *testing.T) {
+	root := &SbomComponent{Name: "project", Version: "1.0.0", PackageUri: "package://host/project@1.0.0", Dependencies: []string{"package://host/a@1.0.0"}}
+	components := []*SbomComponent{
+		{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0", Sha256: "abc", Authors: []string{"me"}, Source: "oci://host/a:1.0.0"},
+		{Name: "local", Version: "1.0.0", PackageUri: "package://host/local@1.0.0", Source: "../local", Local: true},
+	}
+
+	var cdx struct {
+		Components []struct {
+			BomRef string `json:"bom-ref"`
+			Hashes []struct {
+				Content string `json:"content"`
+			} `json:"hashes"`
+		} `json:"components"`
+		Dependencies []struct {
+			Ref       string   `json:"ref"`
+			DependsOn []string `json:"dependsOn"`
+		} `json:"dependencies"`
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCycloneDx(&buf, root, components); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &cdx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(cdx.Components) != 2 || cdx.Components[0].Hashes[0].Content != "abc" || cdx.Dependencies[0].DependsOn[0] != "package://host/a@1.0.0" {
+		t.Errorf("unexpected CycloneDX document %s", buf.String())
+	}
+
+	var spdx struct {
+		Packages []struct {
+			SpdxId           string `json:"SPDXID"`
+			DownloadLocation string `json:"downloadLocation"`
+		} `json:"packages"`
+		Relationships []struct {
+			SpdxElementId      string `json:"spdxElementId"`
+			RelationshipType   string `json:"relationshipType"`
+			RelatedSpdxElement string `json:"relatedSpdxElement"`
+		} `json:"relationships"`
+	}
+
+	buf.Reset()
+	if err := WriteSpdx(&buf, root, components); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &spdx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spdx.Packages) != 3 || spdx.Packages[1].DownloadLocation != "oci://host/a:1.0.0" || spdx.Packages[2].DownloadLocation != "NOASSERTION" {
+		t.Errorf("unexpected SPDX packages %s", buf.String())
+	}
+	if len(spdx.Relationships) != 2 || spdx.Relationships[1].SpdxElementId != "SPDXRef-Package-0" || spdx.Relationships[1].RelatedSpdxElement != "SPDXRef-Package-1" {
+		t.Errorf("unexpected SPDX relationships %+v", spdx.Relationships)
+	}
+}
+
+func TestLicensePolicy(t *testing.T) {
+	isolateHome(t)
+
+	policy := &LicensePolicy{Allow: []string{"MIT", "Apache-2.0", "BSD-3-Clause"}, Deny: []string{"mit"}}
+
+	cases := map[string]bool{
+		"Apache-2.0":                           true,
+		"apache-2.0":                           true,
+		"MIT":                                  false,
+		"GPL-3.0-only":                         false,
+		"":                                     false,
+		"MIT OR Apache-2.0":                    true,
+		"MIT AND Apache-2.0":                   false,
+		"BSD-3-Clause AND (MIT OR Apache-2.0)": true,
+		"Apache-2.0 WITH LLVM-exception":       true,
+		"Apache-2.0 OR":                        false,
+		"(Apache-2.0":                          false,
+	}
+
+	for license, allowed := range cases {
+		if err := policy.Check(license); (err == nil) != allowed {
+			t.Errorf("license %q: expected allowed %v, got %v", license, allowed, err)
+		}
+	}
+
+	r, err := NewResolver(&AppConfig{
+		Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:      context.Background(),
+		CacheDir: t.TempDir(),
+		Offline:  true,
+		config:   &Config{Licenses: &LicensePolicy{Allow: []string{"Apache-2.0"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deduplicated := map[string]*Metadata{
+		"package://host/a@1.0.0": {Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0", License: "Apache-2.0"},
+		"package://host/b@1.0.0": {Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0"},
+	}
+
+	licenses := PackageLicenses(deduplicated, &LicensePolicy{Allow: []string{"Apache-2.0"}})
+	if len(licenses) != 2 || licenses[0].Violation != "" || licenses[1].Violation != "declares no license" {
+		t.Errorf("unexpected licenses %+v %+v", licenses[0], licenses[1])
+	}
+
+	err = r.CheckLicenses(deduplicated)
+	if err == nil || !strings.Contains(err.Error(), "package://host/b@1.0.0 declares no license") {
+		t.Errorf("expected license violation of b, got %v", err)
+	}
+
+	delete(deduplicated, "package://host/b@1.0.0")
+	if err := r.CheckLicenses(deduplicated); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdmissionPolicy(t *testing.T) {
+	isolateHome(t)
+
+	cacheDir := t.TempDir()
+
+	writeCachedPackage(t, cacheDir, Metadata{Name: "a", Version: "1.0.0", PackageUri: "package://host/a@1.0.0",
+		Dependencies: map[string]Dependency{"b": {Uri: "package://host/b@1.0.0"}, "c": {Uri: "package://host/c@^1.0"}}})
+	writeCachedPackage(t, cacheDir, Metadata{Name: "b", Version: "1.0.0", PackageUri: "package://host/b@1.0.0"})
+	for _, version := range []string{"1.0.0", "1.2.0", "1.5.0"} {
+		writeCachedPackage(t, cacheDir, Metadata{Name: "c", Version: version, PackageUri: "package://host/c@" + version,
+			Dependencies: map[string]Dependency{"b": {Uri: "package://host/b@1.0.0"}}})
+	}
+
+	resolve := func(policy *AdmissionPolicy) (map[string]*Metadata, error) {
+		r, err := NewResolver(&AppConfig{
+			Logger:   logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+			ctx:      context.Background(),
+			CacheDir: cacheDir,
+			Offline:  true,
+			Strategy: StrategyMinimal,
+			config:   &Config{Policy: policy},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.Resolve(context.Background(), map[string]Dependency{"a": {Name: "a", Uri: "package://host/a@1.0.0"}})
+	}
+
+	resolved, err := resolve(&AdmissionPolicy{
+		AllowedHosts:    []string{"ho*"},
+		Banned:          []*BannedPackage{{Package: "package://host/c", Versions: "1.0.0"}},
+		MinimumVersions: map[string]string{"package://host/b": "1.0.0"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resolved["package://host/c@1.2.0"]; !ok {
+		t.Errorf("expected the range to skip the banned version, got %v", resolved)
+	}
+
+	_, err = resolve(&AdmissionPolicy{Banned: []*BannedPackage{{Package: "package://host/b", Reason: "CVE-1"}}})
+	expected := "package package://host/b@1.0.0 is not admitted: banned, CVE-1, required by PklProject -> package://host/a@1.0.0; " +
+		"PklProject -> package://host/a@1.0.0 -> package://host/c@1.0.0"
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected %q, got %v", expected, err)
+	}
+
+	_, err = resolve(&AdmissionPolicy{MinimumVersions: map[string]string{"package://host/c": "2.0.0"}})
+	if err == nil || !strings.Contains(err.Error(), "package://host/c@^1.0 is not admitted: every version satisfying the constraint is rejected") {
+		t.Errorf("expected minimum version violation, got %v", err)
+	}
+
+	_, err = resolve(&AdmissionPolicy{AllowedHosts: []string{"pkg.pkl-lang.org"}})
+	if err == nil || err.Error() != "package package://host/a@1.0.0 is not admitted: host host is not allowed, required by PklProject" {
+		t.Errorf("expected host violation, got %v", err)
+	}
+}
+
+func TestHttpAuth(t *testing.T) {
+	isolateHome(t)
+
+	authorization := map[string]string{}
+	archives := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization["archive"] = req.Header.Get("Authorization") + req.Header.Get("Private-Token")
+		fmt.Fprint(w, "zip")
+	}))
+	defer archives.Close()
+
+	metadataHandler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		authorization["metadata"] = req.Header.Get("Authorization") + req.Header.Get("Private-Token")
+		fmt.Fprintf(w, `{"name": "a", "version": "1.0.0", "packageUri": "package://%s/a@1.0.0", "packageZipUrl": "%s/a.zip"}`, req.Host, archives.URL)
+	})
+
+	server := httptest.NewTLSServer(metadataHandler)
+	defer server.Close()
+
+	plainServer := httptest.NewServer(metadataHandler)
+	defer plainServer.Close()
+
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "netrc"))
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	plainHost := strings.TrimPrefix(plainServer.URL, "http://")
+	archiveHost := strings.TrimPrefix(archives.URL, "https://")
+
+	cases := map[string]struct {
+		host     string
+		configs  []*HttpAuthConfig
+		metadata string
+		archive  string
+	}{
+		"any host":           {host, []*HttpAuthConfig{{Host: "127.0.0.1:*", Token: "secret", Headers: map[string]string{"PRIVATE-TOKEN": "gl"}}}, "Bearer secretgl", "Bearer secretgl"},
+		"metadata host only": {host, []*HttpAuthConfig{{Host: host, Token: "secret"}}, "Bearer secret", ""},
+		"archive host":       {host, []*HttpAuthConfig{{Host: archiveHost, Token: "zip"}, {Host: host, Token: "secret"}}, "Bearer secret", "Bearer zip"},
+		"other host":         {host, []*HttpAuthConfig{{Host: "gitlab.example.com", Token: "secret"}}, "", ""},
+		"plain http":         {plainHost, []*HttpAuthConfig{{Host: "127.0.0.1:*", Token: "secret", Headers: map[string]string{"PRIVATE-TOKEN": "gl"}}}, "", "Bearer secretgl"},
+	}
+
+	for name, c := range cases {
+		authorization = map[string]string{}
+
+		config := &AppConfig{
+			Logger:    logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+			Transport: httpclient.TransportOptions{Insecure: true},
+			config:    &Config{HttpAuth: c.configs},
+		}
+		resolver, err := NewHttpResolver(config, c.host == plainHost)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		metadata, err := resolver.ResolveMetadata(context.Background(), fmt.Sprintf("package://%s/a@1.0.0", c.host))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		archive, err := resolver.ResolveArchive(context.Background(), metadata)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		archive.Close()
+
+		expected := map[string]string{"metadata": c.metadata, "archive": c.archive}
+		for _, request := range []string{"metadata", "archive"} {
+			if authorization[request] != expected[request] {
+				t.Errorf("%s: expected credentials %q of %s request, got %q", name, expected[request], request, authorization[request])
+			}
+		}
+	}
+
+	netrc := parseNetrc("machine gitlab.example.com login user password pass\nmacdef init\nmachine evil.com\n\ndefault login anon password x\n")
+	auth := &HttpAuth{netrc: netrc, getenv: func(name string) string {
+		return map[string]string{"HPKL_HTTP_TOKEN_ARTIFACTORY_EXAMPLE_COM_8443": "env"}[name]
+	}}
+
+	if c := auth.For("gitlab.example.com:443"); c == nil || c.Username != "user" || c.Password != "pass" {
+		t.Errorf("expected netrc credentials, got %+v", c)
+	}
+	if c := auth.For("artifactory.example.com:8443"); c == nil || c.Token != "env" {
+		t.Errorf("expected token from the environment, got %+v", c)
+	}
+	if c := auth.For("evil.com"); c != nil {
+		t.Errorf("expected neither macro nor default netrc credentials, got %+v", c)
+	}
+}
+
+func TestHttpResolveConcurrently(t *testing.T) {
+	isolateHome(t)
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		name := strings.TrimPrefix(strings.Split(req.URL.Path, "@")[0], "/")
+		fmt.Fprintf(w, `{"name": "%s", "version": "1.0.0", "packageUri": "package://%s/%s@1.0.0"}`, name, req.Host, name)
+	}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	config := &AppConfig{
+		Logger:          logger.New(new(bytes.Buffer), new(bytes.Buffer)),
+		ctx:             context.Background(),
+		CacheDir:        t.TempDir(),
+		Transport:       httpclient.TransportOptions{Insecure: true},
+		NoMetadataCache: true,
+		config:          &Config{HttpAuth: []*HttpAuthConfig{{Host: host, Token: "secret"}}},
+	}
+
+	r, err := NewResolver(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dependencies := map[string]Dependency{}
+	for _, name := range []string{"a", "b", "c", "d"} {
+		dependencies[name] = Dependency{Name: name, Uri: fmt.Sprintf("package://%s/%s@1.0.0", host, name)}
+	}
+
+	resolved, err := r.Resolve(context.Background(), dependencies)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resolved) != 4 {
+		t.Errorf("expected 4 packages, got %d", len(resolved))
+	}
+
+	client, _ := config.HttpClient()
+	config.Reset()
+	if other, _ := config.HttpClient(); other == client {
+		t.Error("expected Reset to drop the http client of the previous working directory")
+	}
+}
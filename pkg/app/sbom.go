@@ -0,0 +1,381 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+const (
+	SbomCycloneDx = "cyclonedx"
+	SbomSpdx      = "spdx"
+)
+
+// SbomComponent is a package of the bill of materials, either a resolved remote package or a local dependency
+type SbomComponent struct {
+	Name         string
+	Version      string
+	PackageUri   string
+	Sha256       string
+	Authors      []string
+	License      string
+	Source       string
+	Local        bool
+	Dependencies []string
+}
+
+// SbomComponents describes deduplicated packages and their dependencies, sorted by package uri
+func (r *Resolver) SbomComponents(deduplicated map[string]*Metadata) ([]*SbomComponent, error) {
+	selected, err := r.byMajorVersion(deduplicated)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*SbomComponent
+
+	for _, m := range deduplicated {
+		component := &SbomComponent{
+			Name:       m.Name,
+			Version:    m.Version,
+			PackageUri: m.PackageUri,
+			Sha256:     m.PackageZipChecksums.Sha256,
+			Authors:    m.Authors,
+			License:    m.License,
+			Source:     packageSource(m),
+		}
+
+		for _, dep := range m.Dependencies {
+			sub, err := r.selectedDependency(m.PackageUri, dep, selected)
+			if err != nil {
+				return nil, err
+			}
+			component.Dependencies = append(component.Dependencies, sub.PackageUri)
+		}
+		sort.Strings(component.Dependencies)
+
+		result = append(result, component)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].PackageUri < result[j].PackageUri
+	})
+
+	return result, nil
+}
+
+// SbomRoot describes the project itself depending on its declared remote dependencies
+func (r *Resolver) SbomRoot(name string, version string, uri string, dependencies map[string]Dependency, deduplicated map[string]*Metadata) (*SbomComponent, error) {
+	selected, err := r.byMajorVersion(deduplicated)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &SbomComponent{Name: name, Version: version, PackageUri: uri}
+
+	for _, dep := range dependencies {
+		m, err := r.selectedDependency(projectRequirement, dep, selected)
+		if err != nil {
+			return nil, err
+		}
+		root.Dependencies = append(root.Dependencies, m.PackageUri)
+	}
+	sort.Strings(root.Dependencies)
+
+	return root, nil
+}
+
+// packageSource is the location a package archive is fetched from, an OCI reference or the zip url
+func packageSource(m *Metadata) string {
+	if m.Source == nil || m.Source.Type != SourceOci {
+		return m.PackageZipUrl
+	}
+
+	uri := m.PackageUri
+	if mirror, ok := m.Source.Resolver.(*MirrorResolver); ok {
+		uri, _ = mirror.rewrite.Apply(uri)
+	}
+
+	ref, err := pklutils.PklUriToRef(uri)
+	if err != nil {
+		return m.PackageZipUrl
+	}
+
+	return "oci://" + ref
+}
+
+func uuid() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+type (
+	cdxBom struct {
+		BomFormat    string          `json:"bomFormat"`
+		SpecVersion  string          `json:"specVersion"`
+		SerialNumber string          `json:"serialNumber"`
+		Version      int             `json:"version"`
+		Metadata     cdxMetadata     `json:"metadata"`
+		Components   []cdxComponent  `json:"components"`
+		Dependencies []cdxDependency `json:"dependencies"`
+	}
+
+	cdxMetadata struct {
+		Timestamp string       `json:"timestamp"`
+		Tools     cdxTools     `json:"tools"`
+		Component cdxComponent `json:"component"`
+	}
+
+	cdxTools struct {
+		Components []cdxComponent `json:"components"`
+	}
+
+	cdxComponent struct {
+		Type               string        `json:"type"`
+		BomRef             string        `json:"bom-ref,omitempty"`
+		Name               string        `json:"name"`
+		Version            string        `json:"version,omitempty"`
+		Authors            []cdxContact  `json:"authors,omitempty"`
+		Hashes             []cdxHash     `json:"hashes,omitempty"`
+		Licenses           []cdxLicense  `json:"licenses,omitempty"`
+		ExternalReferences []cdxExtRef   `json:"externalReferences,omitempty"`
+		Properties         []cdxProperty `json:"properties,omitempty"`
+	}
+
+	cdxContact struct {
+		Name string `json:"name"`
+	}
+
+	cdxHash struct {
+		Alg     string `json:"alg"`
+		Content string `json:"content"`
+	}
+
+	cdxLicense struct {
+		Expression string `json:"expression"`
+	}
+
+	cdxExtRef struct {
+		Type string `json:"type"`
+		Url  string `json:"url"`
+	}
+
+	cdxProperty struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+	}
+
+	cdxDependency struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	}
+)
+
+func (c *SbomComponent) cycloneDx() cdxComponent {
+	component := cdxComponent{
+		Type:       "library",
+		BomRef:     c.PackageUri,
+		Name:       c.Name,
+		Version:    c.Version,
+		Properties: []cdxProperty{{Name: "hpkl:packageUri", Value: c.PackageUri}},
+	}
+
+	for _, author := range c.Authors {
+		component.Authors = append(component.Authors, cdxContact{Name: author})
+	}
+
+	if c.Sha256 != "" {
+		component.Hashes = []cdxHash{{Alg: "SHA-256", Content: c.Sha256}}
+	}
+
+	if c.License != "" {
+		component.Licenses = []cdxLicense{{Expression: c.License}}
+	}
+
+	if c.Local {
+		component.Properties = append(component.Properties, cdxProperty{Name: "hpkl:localPath", Value: c.Source})
+	} else if c.Source != "" {
+		component.ExternalReferences = []cdxExtRef{{Type: "distribution", Url: c.Source}}
+	}
+
+	return component
+}
+
+// WriteCycloneDx writes the CycloneDX 1.6 JSON document of root and its components
+func WriteCycloneDx(w io.Writer, root *SbomComponent, components []*SbomComponent) error {
+	rootComponent := root.cycloneDx()
+	rootComponent.Type = "application"
+
+	bom := cdxBom{
+		BomFormat:    "CycloneDX",
+		SpecVersion:  "1.6",
+		SerialNumber: "urn:uuid:" + uuid(),
+		Version:      1,
+		Metadata: cdxMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools:     cdxTools{Components: []cdxComponent{{Type: "application", Name: "hpkl"}}},
+			Component: rootComponent,
+		},
+		Components:   []cdxComponent{},
+		Dependencies: []cdxDependency{{Ref: root.PackageUri, DependsOn: nonNil(root.Dependencies)}},
+	}
+
+	for _, c := range components {
+		bom.Components = append(bom.Components, c.cycloneDx())
+		bom.Dependencies = append(bom.Dependencies, cdxDependency{Ref: c.PackageUri, DependsOn: nonNil(c.Dependencies)})
+	}
+
+	return writeJson(w, bom)
+}
+
+type (
+	spdxDocument struct {
+		SpdxVersion       string             `json:"spdxVersion"`
+		DataLicense       string             `json:"dataLicense"`
+		SpdxId            string             `json:"SPDXID"`
+		Name              string             `json:"name"`
+		DocumentNamespace string             `json:"documentNamespace"`
+		CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+		Packages          []spdxPackage      `json:"packages"`
+		Relationships     []spdxRelationship `json:"relationships"`
+	}
+
+	spdxCreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	}
+
+	spdxPackage struct {
+		Name             string         `json:"name"`
+		SpdxId           string         `json:"SPDXID"`
+		VersionInfo      string         `json:"versionInfo,omitempty"`
+		DownloadLocation string         `json:"downloadLocation"`
+		FilesAnalyzed    bool           `json:"filesAnalyzed"`
+		Checksums        []spdxChecksum `json:"checksums,omitempty"`
+		Originator       string         `json:"originator,omitempty"`
+		SourceInfo       string         `json:"sourceInfo,omitempty"`
+		LicenseConcluded string         `json:"licenseConcluded"`
+		LicenseDeclared  string         `json:"licenseDeclared"`
+		CopyrightText    string         `json:"copyrightText"`
+		ExternalRefs     []spdxExtRef   `json:"externalRefs,omitempty"`
+	}
+
+	spdxChecksum struct {
+		Algorithm     string `json:"algorithm"`
+		ChecksumValue string `json:"checksumValue"`
+	}
+
+	spdxExtRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+
+	spdxRelationship struct {
+		SpdxElementId      string `json:"spdxElementId"`
+		RelationshipType   string `json:"relationshipType"`
+		RelatedSpdxElement string `json:"relatedSpdxElement"`
+	}
+)
+
+func (c *SbomComponent) spdx(id string) spdxPackage {
+	p := spdxPackage{
+		Name:             c.Name,
+		SpdxId:           id,
+		VersionInfo:      c.Version,
+		DownloadLocation: "NOASSERTION",
+		LicenseConcluded: "NOASSERTION",
+		LicenseDeclared:  "NOASSERTION",
+		CopyrightText:    "NOASSERTION",
+	}
+
+	if c.Source != "" && !c.Local {
+		p.DownloadLocation = c.Source
+	}
+
+	if c.License != "" {
+		p.LicenseDeclared = c.License
+	}
+
+	if c.Sha256 != "" {
+		p.Checksums = []spdxChecksum{{Algorithm: "SHA256", ChecksumValue: c.Sha256}}
+	}
+
+	if len(c.Authors) > 0 {
+		p.Originator = "Person: " + strings.Join(c.Authors, ", ")
+	}
+
+	if c.Local {
+		p.SourceInfo = "local dependency at " + c.Source
+	}
+
+	if c.PackageUri != "" {
+		p.ExternalRefs = []spdxExtRef{{ReferenceCategory: "OTHER", ReferenceType: "pkl-package", ReferenceLocator: c.PackageUri}}
+	}
+
+	return p
+}
+
+// WriteSpdx writes the SPDX 2.3 JSON document of root and its components
+func WriteSpdx(w io.Writer, root *SbomComponent, components []*SbomComponent) error {
+	ids := map[string]string{root.PackageUri: "SPDXRef-Package-0"}
+	for i, c := range components {
+		ids[c.PackageUri] = fmt.Sprintf("SPDXRef-Package-%d", i+1)
+	}
+
+	doc := spdxDocument{
+		SpdxVersion:       "SPDX-2.3",
+		DataLicense:       "CC0-1.0",
+		SpdxId:            "SPDXRef-DOCUMENT",
+		Name:              root.Name,
+		DocumentNamespace: fmt.Sprintf("https://hpkl.io/spdx/%s-%s", root.Name, uuid()),
+		CreationInfo: spdxCreationInfo{
+			Created:  time.Now().UTC().Format(time.RFC3339),
+			Creators: []string{"Tool: hpkl"},
+		},
+		Packages: []spdxPackage{root.spdx(ids[root.PackageUri])},
+		Relationships: []spdxRelationship{
+			{SpdxElementId: "SPDXRef-DOCUMENT", RelationshipType: "DESCRIBES", RelatedSpdxElement: ids[root.PackageUri]},
+		},
+	}
+
+	for _, c := range components {
+		doc.Packages = append(doc.Packages, c.spdx(ids[c.PackageUri]))
+	}
+
+	for _, c := range append([]*SbomComponent{root}, components...) {
+		for _, dep := range c.Dependencies {
+			doc.Relationships = append(doc.Relationships, spdxRelationship{
+				SpdxElementId:      ids[c.PackageUri],
+				RelationshipType:   "DEPENDS_ON",
+				RelatedSpdxElement: ids[dep],
+			})
+		}
+	}
+
+	return writeJson(w, doc)
+}
+
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+func writeJson(w io.Writer, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(data))
+	return err
+}
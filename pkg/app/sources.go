@@ -0,0 +1,345 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+const (
+	SourceOci   = "oci"
+	SourceHttps = "https"
+	SourceHttp  = "http"
+	SourceFile  = "file"
+	SourceDir   = "dir"
+	SourceCache = "cache"
+)
+
+type (
+	// SourceConfig selects the source of packages matching a host (zot.internal, *.internal)
+	// or uri pattern (package://zot.internal/team/*)
+	SourceConfig struct {
+		Match     string `json:"match"`
+		Type      string `json:"type"`
+		Path      string `json:"path"`
+		PlainHttp bool   `json:"plainHttp"`
+	}
+
+	Source struct {
+		Type     string
+		Match    string
+		Resolver DependencyResolver
+		pattern  *regexp.Regexp
+	}
+
+	// Sources is the registry of dependency resolvers chosen per package host or uri pattern
+	Sources struct {
+		sources  []*Source
+		builtins map[string]*Source
+		fallback *Source
+	}
+
+	// FileResolver reads packages laid out as produced by pkl project package:
+	// <path>/<host>/<package path>@<version> metadata and <path>/<host>/<package path>@<version>.zip archive
+	FileResolver struct {
+		path string
+	}
+
+	// DirResolver reads packages from a directory with the pkl cache layout:
+	// <path>/<host>/<package path>@<version>/<name>@<version>.json and .zip
+	DirResolver struct {
+		path string
+	}
+
+	MissingPackageError struct {
+		Uri string
+	}
+)
+
+func (e *MissingPackageError) Error() string {
+	return fmt.Sprintf("package %s is missing", e.Uri)
+}
+
+// NewCacheSources serves every dependency from the local package cache, used in offline mode
+func NewCacheSources(cachePath string) *Sources {
+	return &Sources{
+		builtins: make(map[string]*Source),
+		fallback: &Source{Type: SourceCache, Resolver: &DirResolver{path: cachePath}},
+	}
+}
+
+func NewSources(appConfig *AppConfig, config *Config) (*Sources, error) {
+	sources := &Sources{builtins: make(map[string]*Source)}
+
+	// mirror rewrites take precedence over sources of the original hosts
+	var rewrites []*Rewrite
+	for _, c := range config.Rewrites {
+		source, rewrite, err := newMirrorSource(appConfig, c)
+		if err != nil {
+			return nil, err
+		}
+		rewrites = append(rewrites, rewrite)
+		sources.sources = append(sources.sources, source)
+	}
+
+	// package zip urls of mirrored packages are rewritten by any matching rule
+	for _, source := range sources.sources {
+		source.Resolver.(*MirrorResolver).rewrites = rewrites
+	}
+
+	for _, c := range config.Sources {
+		source, err := NewSource(appConfig, c)
+		if err != nil {
+			return nil, err
+		}
+		sources.sources = append(sources.sources, source)
+	}
+
+	// sources used for dependencies that do not match any configured pattern
+	oci, err := NewOciResolver(appConfig, appConfig.PlainHttp)
+	if err != nil {
+		return nil, err
+	}
+	plainOci, err := NewOciResolver(appConfig, true)
+	if err != nil {
+		return nil, err
+	}
+
+	https, err := NewHttpResolver(appConfig, appConfig.PlainHttp)
+	if err != nil {
+		return nil, err
+	}
+	plainHttps, err := NewHttpResolver(appConfig, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sources.builtins[SourceOci] = &Source{Type: SourceOci, Resolver: oci}
+	sources.builtins[SourceOci+"+"+SourceHttp] = &Source{Type: SourceOci, Resolver: plainOci}
+	sources.builtins[SourceHttps] = &Source{Type: SourceHttps, Resolver: https}
+	sources.builtins[SourceHttp] = &Source{Type: SourceHttp, Resolver: plainHttps}
+
+	sources.fallback = sources.builtins[SourceHttps]
+	if appConfig.PlainHttp {
+		sources.fallback = sources.builtins[SourceHttp]
+	}
+
+	return sources, nil
+}
+
+func NewSource(appConfig *AppConfig, config *SourceConfig) (*Source, error) {
+	var resolver DependencyResolver
+	var err error
+
+	switch config.Type {
+	case SourceOci:
+		resolver, err = NewOciResolver(appConfig, config.PlainHttp || appConfig.PlainHttp)
+	case SourceHttps:
+		resolver, err = NewHttpResolver(appConfig, appConfig.PlainHttp)
+	case SourceHttp:
+		resolver, err = NewHttpResolver(appConfig, true)
+	case SourceFile:
+		resolver = &FileResolver{path: config.Path}
+	case SourceDir:
+		resolver = &DirResolver{path: config.Path}
+	default:
+		return nil, fmt.Errorf("unknown source type %s for %s", config.Type, config.Match)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if (config.Type == SourceFile || config.Type == SourceDir) && config.Path == "" {
+		return nil, fmt.Errorf("source %s for %s requires path", config.Type, config.Match)
+	}
+
+	return &Source{
+		Type:     config.Type,
+		Match:    config.Match,
+		Resolver: resolver,
+		pattern:  globPattern(config.Match),
+	}, nil
+}
+
+// globPattern compiles pattern where * matches and captures any sequence of characters
+func globPattern(pattern string) *regexp.Regexp {
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, "(.*)")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// Matches reports whether the package uri is served by the source,
+// patterns without scheme are matched against the uri host
+func (s *Source) Matches(uri string) bool {
+	if !strings.Contains(s.Match, "://") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return false
+		}
+		return s.pattern.MatchString(u.Host)
+	}
+	return s.pattern.MatchString(uri)
+}
+
+// For returns the source of the dependency. Configured sources are checked in order,
+// then legacy .oci and .plain dependency name suffixes are honored.
+func (s *Sources) For(dependency Dependency) *Source {
+	for _, source := range s.sources {
+		if source.Matches(dependency.Uri) {
+			return source
+		}
+	}
+
+	plain := strings.Contains(dependency.Name, ".plain")
+	legacy := ""
+
+	if strings.HasSuffix(dependency.Name, ".oci") {
+		legacy = SourceOci
+		if plain {
+			legacy = SourceOci + "+" + SourceHttp
+		}
+	} else if plain {
+		legacy = SourceHttp
+	}
+
+	if source, ok := s.builtins[legacy]; ok {
+		return source
+	}
+
+	return s.fallback
+}
+
+func (r *FileResolver) packagePath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return pklutils.PklGetRelativePath(r.path, u), nil
+}
+
+func (r *FileResolver) ResolveMetadata(ctx context.Context, uri string) (*Metadata, error) {
+	metaPath, err := r.packagePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return readMetadata(metaPath)
+}
+
+func (r *FileResolver) ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error) {
+	metaPath, err := r.packagePath(metadata.PackageUri)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(metaPath + ".zip")
+}
+
+func (r *FileResolver) ResolveVersions(ctx context.Context, uri string) ([]string, error) {
+	basePath, err := r.packagePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	return listVersions(filepath.Dir(basePath), filepath.Base(basePath), false)
+}
+
+func (r *DirResolver) packagePath(uri string) (string, string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", err
+	}
+
+	basePath := pklutils.PklGetRelativePath(r.path, u)
+
+	return basePath, path.Base(u.Path), nil
+}
+
+func (r *DirResolver) ResolveMetadata(ctx context.Context, uri string) (*Metadata, error) {
+	basePath, name, err := r.packagePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata, err := readMetadata(filepath.Join(basePath, name+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &MissingPackageError{Uri: uri}
+	}
+
+	return metadata, err
+}
+
+func (r *DirResolver) ResolveArchive(ctx context.Context, metadata *Metadata) (io.ReadCloser, error) {
+	basePath, name, err := r.packagePath(metadata.PackageUri)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(basePath, name+".zip"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &MissingPackageError{Uri: metadata.PackageUri}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (r *DirResolver) ResolveVersions(ctx context.Context, uri string) ([]string, error) {
+	basePath, _, err := r.packagePath(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := listVersions(filepath.Dir(basePath), filepath.Base(basePath), true)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &MissingPackageError{Uri: uri}
+	}
+
+	return versions, err
+}
+
+func readMetadata(metaPath string) (*Metadata, error) {
+	data, err := os.ReadFile(metaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata *Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return nil, err
+	}
+
+	return metadata, nil
+}
+
+// listVersions collects versions from <dir>/<name>@<version> entries
+func listVersions(dir string, name string, dirs bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	prefix := name + "@"
+
+	for _, entry := range entries {
+		entryName := entry.Name()
+		if entry.IsDir() != dirs || !strings.HasPrefix(entryName, prefix) || strings.HasSuffix(entryName, ".zip") {
+			continue
+		}
+		versions = append(versions, strings.TrimPrefix(entryName, prefix))
+	}
+
+	return versions, nil
+}
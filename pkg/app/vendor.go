@@ -0,0 +1,213 @@
+package app
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+// vendorMarker is written into vendor directories created by hpkl, other directories are never removed
+const vendorMarker = ".hpkl-vendor"
+
+// VendorDir validates dir, which must be a subdirectory of the project, and returns its path
+func VendorDir(workingDir string, dir string) (string, error) {
+	clean := filepath.Clean(dir)
+
+	if !filepath.IsLocal(clean) || clean == "." {
+		return "", fmt.Errorf("vendor directory %s must be a subdirectory of the project", dir)
+	}
+
+	return filepath.Join(workingDir, clean), nil
+}
+
+// ResetVendorDir empties a vendor directory written by a previous run, so packages which are not dependencies
+// anymore do not stay around. Existing non-empty directories without the marker are refused.
+func ResetVendorDir(vendorDir string) error {
+	entries, err := os.ReadDir(vendorDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if len(entries) > 0 {
+		if _, err := os.Stat(filepath.Join(vendorDir, vendorMarker)); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%s already exists and was not created by hpkl vendor", vendorDir)
+		} else if err != nil {
+			return err
+		}
+
+		if err := os.RemoveAll(vendorDir); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(vendorDir, 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(filepath.Join(vendorDir, vendorMarker), nil, 0644)
+}
+
+// Vendor extracts downloaded package archives into vendorDir using the package cache layout
+// and generates a PklProject for each of them, so they can be used as local dependencies.
+// It returns the vendored directory of each package uri.
+func (r *Resolver) Vendor(dependencies map[string]*Metadata, vendorDir string) (map[string]string, error) {
+	selected, err := r.byMajorVersion(dependencies)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]string, len(dependencies))
+
+	for _, m := range dependencies {
+		packageUri, err := url.Parse(m.PackageUri)
+		if err != nil {
+			return nil, err
+		}
+
+		_, _, archivePath, err := r.packagePaths(m)
+		if err != nil {
+			return nil, err
+		}
+
+		target := pklutils.PklGetRelativePath(vendorDir, packageUri)
+
+		if err := os.RemoveAll(target); err != nil {
+			return nil, err
+		}
+
+		if err := extractZip(archivePath, target); err != nil {
+			return nil, fmt.Errorf("%s: %w", m.PackageUri, err)
+		}
+
+		project, err := r.vendoredProject(m, selected)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.WriteFile(filepath.Join(target, "PklProject"), []byte(project), 0644); err != nil {
+			return nil, err
+		}
+
+		result[m.PackageUri] = target
+	}
+
+	return result, nil
+}
+
+// vendoredProject renders a PklProject declaring the package and its dependencies pinned to the selected versions
+func (r *Resolver) vendoredProject(m *Metadata, selected map[string]*Metadata) (string, error) {
+	baseUri, _ := pklutils.PklSplitVersion(m.PackageUri)
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "amends \"pkl:Project\"\n\npackage {\n")
+	fmt.Fprintf(&b, "  name = %s\n", pklString(m.Name))
+	fmt.Fprintf(&b, "  baseUri = %s\n", pklString(baseUri))
+	fmt.Fprintf(&b, "  version = %s\n", pklString(m.Version))
+	fmt.Fprintf(&b, "  packageZipUrl = %s\n", pklString(m.PackageZipUrl))
+	if m.License != "" {
+		fmt.Fprintf(&b, "  license = %s\n", pklString(m.License))
+	}
+	if len(m.Authors) > 0 {
+		fmt.Fprintf(&b, "  authors {\n")
+		for _, author := range m.Authors {
+			fmt.Fprintf(&b, "    %s\n", pklString(author))
+		}
+		fmt.Fprintf(&b, "  }\n")
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	if len(m.Dependencies) == 0 {
+		return b.String(), nil
+	}
+
+	names := make([]string, 0, len(m.Dependencies))
+	for name := range m.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(&b, "\ndependencies {\n")
+	for _, name := range names {
+		dep, err := r.selectedDependency(m.PackageUri, m.Dependencies[name], selected)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", m.PackageUri, err)
+		}
+		fmt.Fprintf(&b, "  [%s] { uri = %s }\n", pklString(name), pklString(dep.PackageUri))
+	}
+	fmt.Fprintf(&b, "}\n")
+
+	return b.String(), nil
+}
+
+func extractZip(archivePath string, target string) error {
+	reader, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		name := filepath.FromSlash(f.Name)
+
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("unsafe path %s in archive", f.Name)
+		}
+
+		path := filepath.Join(target, name)
+
+		if f.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !f.Mode().IsRegular() {
+			return fmt.Errorf("unsupported entry %s in archive", f.Name)
+		}
+
+		if err := extractFile(f, path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractFile(f *zip.File, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// pklString quotes s as a Pkl string literal
+func pklString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s) + `"`
+}
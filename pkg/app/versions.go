@@ -0,0 +1,102 @@
+package app
+
+import (
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+)
+
+// IsVersionConstraint reports whether the version part of a package uri is a range
+// (^1.2, ~1.4.0, >=1.0 <2) rather than an exact semantic version
+func IsVersionConstraint(version string) bool {
+	if version == "" {
+		return false
+	}
+	_, err := semver.StrictNewVersion(version)
+	return err != nil
+}
+
+// SelectVersion picks the highest version satisfying the constraint
+func SelectVersion(versions []string, constraint string) (string, error) {
+	return selectVersion(versions, constraint, (*semver.Version).GreaterThan)
+}
+
+// SelectMinimalVersion picks the lowest version satisfying the constraint
+func SelectMinimalVersion(versions []string, constraint string) (string, error) {
+	return selectVersion(versions, constraint, (*semver.Version).LessThan)
+}
+
+func selectVersion(versions []string, constraint string, better func(*semver.Version, *semver.Version) bool) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+
+	if err != nil {
+		return "", err
+	}
+
+	var selected *semver.Version
+
+	for _, v := range versions {
+		version, err := semver.NewVersion(v)
+
+		if err != nil {
+			continue
+		}
+
+		if c.Check(version) && (selected == nil || better(version, selected)) {
+			selected = version
+		}
+	}
+
+	if selected == nil {
+		return "", fmt.Errorf("no version satisfies constraint %s", constraint)
+	}
+
+	return selected.Original(), nil
+}
+
+// satisfies reports whether version meets a requirement declared as an exact version, which is a minimum
+// within its major version, or as a range constraint
+func satisfies(version *semver.Version, requirement string) bool {
+	if IsVersionConstraint(requirement) {
+		c, err := semver.NewConstraint(requirement)
+		return err == nil && c.Check(version)
+	}
+
+	required, err := semver.NewVersion(requirement)
+
+	return err == nil && !version.LessThan(required)
+}
+
+// UpgradeCandidates returns the highest released versions with the same minor version,
+// with the same major version and overall. Current version is returned when nothing newer exists.
+func UpgradeCandidates(versions []string, current string) (string, string, string, error) {
+	currentVersion, err := semver.NewVersion(current)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	patch, minor, major := currentVersion, currentVersion, currentVersion
+
+	for _, v := range versions {
+		version, err := semver.NewVersion(v)
+
+		if err != nil || version.Prerelease() != "" {
+			continue
+		}
+
+		if version.GreaterThan(major) {
+			major = version
+		}
+
+		if version.Major() == currentVersion.Major() && version.GreaterThan(minor) {
+			minor = version
+		}
+
+		if version.Major() == currentVersion.Major() && version.Minor() == currentVersion.Minor() && version.GreaterThan(patch) {
+			patch = version
+		}
+	}
+
+	return patch.Original(), minor.Original(), major.Original(), nil
+}
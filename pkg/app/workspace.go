@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"hpkl.io/hpkl/pkg/pklutils"
+)
+
+const workspacePath = "PklWorkspace"
+
+// Workspace lists member projects resolved together as one dependency graph, e.g.
+//
+//	members { "projects/a"; "projects/b" }
+type Workspace struct {
+	Members []string `json:"members"`
+}
+
+// LoadWorkspace reads the PklWorkspace file of dir, member paths are returned relative to the working directory
+func LoadWorkspace(ctx context.Context, dir string) (*Workspace, error) {
+	path := filepath.Join(dir, workspacePath)
+
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("%s file not found in the working directory %s", workspacePath, dir)
+	}
+
+	var workspace Workspace
+	if err := pklutils.LoadConfig(ctx, path, &workspace); err != nil {
+		return nil, err
+	}
+
+	if len(workspace.Members) == 0 {
+		return nil, fmt.Errorf("%s has no members", path)
+	}
+
+	for i, member := range workspace.Members {
+		workspace.Members[i] = filepath.Join(dir, member)
+	}
+
+	return &workspace, nil
+}
+
+// byMajorVersion indexes deduplicated packages by their major version key
+func (r *Resolver) byMajorVersion(dependencies map[string]*Metadata) (map[string]*Metadata, error) {
+	result := make(map[string]*Metadata, len(dependencies))
+
+	for _, m := range dependencies {
+		key, err := r.MajorVersionPackage(m)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = m
+	}
+
+	return result, nil
+}
+
+// selectedDependency maps a dependency declared by from, possibly with a version range,
+// to the deduplicated package using the requirements recorded by Resolve
+func (r *Resolver) selectedDependency(from string, dependency Dependency, selected map[string]*Metadata) (*Metadata, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, requirements := range r.requirements {
+		for _, req := range requirements {
+			if m, ok := selected[key]; ok && req.From == from && req.Uri == dependency.Uri {
+				return m, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("dependency %s is not resolved", dependency.Uri)
+}
+
+// Reachable returns the deduplicated packages reachable from the project dependencies,
+// when several projects were resolved together each of them gets only the packages it uses
+func (r *Resolver) Reachable(dependencies map[string]Dependency, deduplicated map[string]*Metadata) (map[string]*Metadata, error) {
+	selected, err := r.byMajorVersion(deduplicated)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*Metadata)
+
+	var visit func(from string, dependency Dependency) error
+	visit = func(from string, dependency Dependency) error {
+		m, err := r.selectedDependency(from, dependency, selected)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := result[m.PackageUri]; ok {
+			return nil
+		}
+
+		result[m.PackageUri] = m
+
+		for _, sub := range m.Dependencies {
+			if err := visit(m.PackageUri, sub); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	for _, dependency := range dependencies {
+		if err := visit(projectRequirement, dependency); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"hpkl.io/hpkl/pkg/logger"
+)
+
+type Options struct {
+	Retries      int
+	RetryMaxWait time.Duration
+	Logger       *logger.Logger
+	Transport    TransportOptions
+	Hosts        []HostTransportOptions
+}
+
+// New creates the http client shared by http package resolvers and the registry client
+func New(options Options) (*http.Client, error) {
+	base, err := newHostTransport(options)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &RetryTransport{
+			Base:    base,
+			Retries: options.Retries,
+			MaxWait: options.RetryMaxWait,
+			Logger:  options.Logger,
+		},
+	}, nil
+}
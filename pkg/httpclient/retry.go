@@ -0,0 +1,137 @@
+package httpclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"hpkl.io/hpkl/pkg/logger"
+)
+
+const baseRetryWait = 500 * time.Millisecond
+
+// RetryTransport retries transient failures: timeouts, reset or refused connections, 5xx and 429 responses,
+// with jittered exponential backoff. Retry-After of 429 and 503 responses is honoured.
+// Other 4xx responses, e.g. authentication errors, are returned immediately.
+type RetryTransport struct {
+	Base    http.RoundTripper
+	Retries int
+	MaxWait time.Duration
+	Logger  *logger.Logger
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		// the request of the caller is never modified, retries send a clone with a fresh body
+		attemptReq := req
+		if attempt > 0 {
+			attemptReq = req.Clone(req.Context())
+
+			if req.Body != nil {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+
+		// request bodies which can't be replayed are never retried
+		replayable := req.Body == nil || req.GetBody != nil
+
+		if !replayable || !retryable(req.Context(), resp, err) {
+			return resp, err
+		}
+
+		if attempt >= t.Retries {
+			if t.Logger != nil && attempt > 0 {
+				t.Logger.Error("Giving up %s %s after %d attempts: %s", req.Method, req.URL.Redacted(), attempt+1, failure(resp, err))
+			}
+			return resp, err
+		}
+
+		wait := t.backoff(attempt, resp)
+
+		if t.Logger != nil {
+			t.Logger.Warn("Retrying %s %s (attempt %d/%d) in %s: %s", req.Method, req.URL.Redacted(), attempt+2, t.Retries+1, wait.Round(time.Millisecond), failure(resp, err))
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// failure describes a failed attempt by its error or response status
+func failure(resp *http.Response, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+	return resp.Status
+}
+
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && transient(err)
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented
+}
+
+// transient tells timeouts and dropped or refused connections from errors retries can't fix,
+// e.g. certificate verification failures, unknown hosts or unsupported schemes
+func transient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func (t *RetryTransport) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(wait, t.MaxWait)
+		}
+	}
+
+	wait := min(baseRetryWait<<attempt, t.MaxWait)
+
+	// full jitter in the upper half of the interval
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// retryAfter parses Retry-After header given either in seconds or as http date
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+
+	return 0, false
+}
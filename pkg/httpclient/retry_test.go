@@ -0,0 +1,177 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"hpkl.io/hpkl/pkg/logger"
+)
+
+func newTestClient(log *bytes.Buffer) *http.Client {
+	client, err := New(Options{Retries: 3, RetryMaxWait: 10 * time.Millisecond, Logger: logger.New(log, log)})
+	if err != nil {
+		panic(err)
+	}
+	return client
+}
+
+func TestRetryTransientFailures(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch calls.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer server.Close()
+
+	log := new(bytes.Buffer)
+	resp, err := newTestClient(log).Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || calls.Load() != 3 {
+		t.Errorf("expected success after 3 calls, got %s after %d", resp.Status, calls.Load())
+	}
+
+	if bytes.Count(log.Bytes(), []byte("Warning: Retrying GET")) != 2 || bytes.Contains(log.Bytes(), []byte("Giving up")) {
+		t.Errorf("expected two logged retries, got: %s", log.String())
+	}
+}
+
+func TestRetryFailsFastOnAuthErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	resp, err := newTestClient(new(bytes.Buffer)).Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusUnauthorized || calls.Load() != 1 {
+		t.Errorf("expected single unauthorized call, got %s after %d", resp.Status, calls.Load())
+	}
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	log := new(bytes.Buffer)
+	resp, err := newTestClient(log).Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadGateway || calls.Load() != 4 {
+		t.Errorf("expected bad gateway after 4 calls, got %s after %d", resp.Status, calls.Load())
+	}
+
+	if bytes.Count(log.Bytes(), []byte("Warning: Retrying GET")) != 3 || !bytes.Contains(log.Bytes(), []byte("Giving up GET "+server.URL+" after 4 attempts: 502 Bad Gateway")) {
+		t.Errorf("expected three retries and the final failure logged, got: %s", log.String())
+	}
+}
+
+func TestRetryReplaysBody(t *testing.T) {
+	var bodies []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := req.Body
+
+	resp, err := newTestClient(new(bytes.Buffer)).Transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if strings.Join(bodies, ",") != "body,body,body" {
+		t.Errorf("expected body in every attempt, got %q", bodies)
+	}
+
+	if req.Body != body {
+		t.Error("expected body of the request to be left unchanged")
+	}
+}
+
+type countingTransport struct {
+	calls atomic.Int32
+	base  http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.calls.Add(1)
+	return t.base.RoundTrip(req)
+}
+
+func TestRetryOnlyTransientErrors(t *testing.T) {
+	tlsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer tlsServer.Close()
+
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	cases := map[string]struct {
+		url   string
+		calls int32
+	}{
+		"untrusted certificate": {tlsServer.URL, 1},
+		"unsupported scheme":    {"ftp://127.0.0.1/a.zip", 1},
+		"refused connection":    {closed.URL, 4},
+	}
+
+	for name, c := range cases {
+		base := &countingTransport{base: http.DefaultTransport}
+		client := &http.Client{Transport: &RetryTransport{Base: base, Retries: 3, MaxWait: 10 * time.Millisecond}}
+
+		resp, err := client.Get(c.url)
+		if err == nil {
+			resp.Body.Close()
+			t.Errorf("%s: expected an error", name)
+		}
+
+		if base.calls.Load() != c.calls {
+			t.Errorf("%s: expected %d calls, got %d: %v", name, c.calls, base.calls.Load(), err)
+		}
+	}
+}
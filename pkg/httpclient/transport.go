@@ -0,0 +1,193 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// ProxyDirect as proxy disables proxies, including HTTPS_PROXY of the environment
+const ProxyDirect = "direct"
+
+type (
+	// TransportOptions are TLS and proxy settings of connections. CaFile is trusted in addition to system roots,
+	// KeyFile may be omitted when CertFile contains the key. Without Proxy HTTP(S)_PROXY and NO_PROXY are honoured.
+	TransportOptions struct {
+		CaFile   string `json:"caFile"`
+		CertFile string `json:"certFile"`
+		KeyFile  string `json:"keyFile"`
+		Proxy    string `json:"proxy"`
+		Insecure bool   `json:"insecureSkipVerify"`
+	}
+
+	// HostTransportOptions override global settings for hosts matching Host (registry.internal, *.corp.example.com)
+	HostTransportOptions struct {
+		Host string `json:"host"`
+		TransportOptions
+	}
+
+	// hostTransport sends requests through the transport configured for their host
+	hostTransport struct {
+		hosts      []HostTransportOptions
+		transports []http.RoundTripper
+		fallback   http.RoundTripper
+	}
+)
+
+// Merge applies host settings over o, except CaFile which is trusted in addition to the one of o
+func (o TransportOptions) Merge(host TransportOptions) TransportOptions {
+	merged := o
+
+	if host.CertFile != "" {
+		merged.CertFile = host.CertFile
+		merged.KeyFile = host.KeyFile
+	}
+
+	if host.Proxy != "" {
+		merged.Proxy = host.Proxy
+	}
+
+	merged.Insecure = o.Insecure || host.Insecure
+
+	return merged
+}
+
+// ResolvePaths makes relative file paths relative to dir, e.g. the directory of the configuration file
+func (o *TransportOptions) ResolvePaths(dir string) {
+	for _, p := range []*string{&o.CaFile, &o.CertFile, &o.KeyFile} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+}
+
+// matchHost matches host with or without port against a pattern, * matches any part of the host
+func matchHost(pattern string, u *url.URL) bool {
+	for _, host := range []string{u.Host, u.Hostname()} {
+		if ok, _ := path.Match(pattern, host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// For returns settings of connections to host for clients accepting a single CA file,
+// the CA file of the host replaces the global one
+func (o Options) For(host string) TransportOptions {
+	u := &url.URL{Host: host}
+
+	for _, h := range o.Hosts {
+		if matchHost(h.Host, u) {
+			merged := o.Transport.Merge(h.TransportOptions)
+			if h.CaFile != "" {
+				merged.CaFile = h.CaFile
+			}
+			return merged
+		}
+	}
+
+	return o.Transport
+}
+
+func newTransport(options TransportOptions, caFiles ...string) (*http.Transport, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: options.Insecure}
+
+	if len(caFiles) > 0 {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		for _, caFile := range caFiles {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", caFile)
+			}
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
+	if options.CertFile != "" {
+		keyFile := options.KeyFile
+		if keyFile == "" {
+			keyFile = options.CertFile
+		}
+
+		cert, err := tls.LoadX509KeyPair(options.CertFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	transport.TLSClientConfig = tlsConfig
+
+	switch options.Proxy {
+	case "":
+	case ProxyDirect:
+		transport.Proxy = nil
+	default:
+		proxy, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("proxy %s: %w", options.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+
+	return transport, nil
+}
+
+func caFiles(files ...string) []string {
+	var result []string
+	for _, f := range files {
+		if f != "" {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// newHostTransport creates transports of the global settings and of every configured host
+func newHostTransport(options Options) (http.RoundTripper, error) {
+	fallback, err := newTransport(options.Transport, caFiles(options.Transport.CaFile)...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(options.Hosts) == 0 {
+		return fallback, nil
+	}
+
+	t := &hostTransport{hosts: options.Hosts, fallback: fallback}
+
+	for _, h := range options.Hosts {
+		transport, err := newTransport(options.Transport.Merge(h.TransportOptions), caFiles(options.Transport.CaFile, h.CaFile)...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", h.Host, err)
+		}
+		t.transports = append(t.transports, transport)
+	}
+
+	return t, nil
+}
+
+func (t *hostTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for i, h := range t.hosts {
+		if matchHost(h.Host, req.URL) {
+			return t.transports[i].RoundTrip(req)
+		}
+	}
+	return t.fallback.RoundTrip(req)
+}
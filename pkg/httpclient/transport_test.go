@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeCertificate(t *testing.T, server *httptest.Server) string {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestTransportOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	defer server.Close()
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	caFile := writeCertificate(t, server)
+
+	cases := map[string]struct {
+		options Options
+		ok      bool
+	}{
+		"system roots":       {Options{}, false},
+		"ca bundle":          {Options{Transport: TransportOptions{CaFile: caFile}}, true},
+		"per host ca bundle": {Options{Hosts: []HostTransportOptions{{Host: "127.0.0.1", TransportOptions: TransportOptions{CaFile: caFile}}}}, true},
+		"other host":         {Options{Hosts: []HostTransportOptions{{Host: "*.internal", TransportOptions: TransportOptions{Insecure: true}}}}, false},
+		"per host insecure":  {Options{Hosts: []HostTransportOptions{{Host: host, TransportOptions: TransportOptions{Insecure: true}}}}, true},
+		"global insecure":    {Options{Transport: TransportOptions{Insecure: true}}, true},
+		"proxy":              {Options{Transport: TransportOptions{CaFile: caFile, Proxy: "http://127.0.0.1:1"}}, false},
+		"direct over proxy":  {Options{Transport: TransportOptions{CaFile: caFile, Proxy: "http://127.0.0.1:1"}, Hosts: []HostTransportOptions{{Host: "127.0.0.1", TransportOptions: TransportOptions{Proxy: ProxyDirect}}}}, true},
+	}
+
+	for name, c := range cases {
+		client, err := New(c.options)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if (err == nil) != c.ok {
+			t.Errorf("%s: expected success %v, got %v", name, c.ok, err)
+		}
+	}
+
+	if _, err := New(Options{Transport: TransportOptions{CaFile: filepath.Join(t.TempDir(), "missing.pem")}}); err == nil {
+		t.Error("expected error of missing CA bundle")
+	}
+
+	merged := Options{
+		Transport: TransportOptions{CaFile: "global.pem", CertFile: "global.crt", KeyFile: "global.key"},
+		Hosts:     []HostTransportOptions{{Host: "*.internal", TransportOptions: TransportOptions{CaFile: "internal.pem", Insecure: true}}},
+	}.For("registry.internal:5000")
+
+	expected := TransportOptions{CaFile: "internal.pem", CertFile: "global.crt", KeyFile: "global.key", Insecure: true}
+	if merged != expected {
+		t.Errorf("expected %+v, got %+v", expected, merged)
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writeCertificate(t, server)
+
+	// the test server certificate serves as client certificate too
+	certFile := filepath.Join(t.TempDir(), "client.pem")
+	cert := server.TLS.Certificates[0]
+	key, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: key})...)
+	if err := os.WriteFile(certFile, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, options := range []TransportOptions{{CaFile: caFile}, {CaFile: caFile, CertFile: certFile}} {
+		client, err := New(Options{Transport: options})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if (err == nil) != (options.CertFile != "") {
+			t.Errorf("client certificate %q: unexpected result %v", options.CertFile, err)
+		}
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 type Logger struct {
+	mu  sync.Mutex
 	out io.Writer
 	err io.Writer
 }
@@ -18,7 +20,16 @@ func New(outWriter io.Writer, errWriter io.Writer) *Logger {
 	}
 }
 
+// SetOut redirects informational output, e.g. to keep stdout clean for machine readable command output
+func (l *Logger) SetOut(outWriter io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.out = outWriter
+}
+
 func (l *Logger) Log(def io.Writer, s string, a ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 	fmt.Fprintln(def, fmt.Sprintf(s, a...))
 }
 
@@ -26,6 +37,10 @@ func (l *Logger) Info(s string, a ...any) {
 	l.Log(l.out, s, a...)
 }
 
+func (l *Logger) Warn(s string, a ...any) {
+	l.Log(l.err, "Warning: "+s, a...)
+}
+
 func (l *Logger) Error(s string, a ...any) {
 	l.Log(l.err, s, a...)
 }
@@ -6,6 +6,8 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -35,6 +37,92 @@ func PklWriteDeps(workingDir string, deps *ProjectDeps) error {
 	return err
 }
 
+func PklReadDeps(workingDir string) (*ProjectDeps, error) {
+	depsData, err := os.ReadFile(filepath.Join(workingDir, "PklProject.deps.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var deps ProjectDeps
+	if err := json.Unmarshal(depsData, &deps); err != nil {
+		return nil, err
+	}
+
+	return &deps, nil
+}
+
+// DiffDeps returns human readable differences between two resolved dependency sets,
+// a changed schema version first, then one line per added, removed or changed dependency, sorted by dependency key
+func DiffDeps(old *ProjectDeps, new *ProjectDeps) []string {
+	var diff []string
+
+	if old.SchemaVersion != new.SchemaVersion {
+		diff = append(diff, fmt.Sprintf("~ schemaVersion %d -> %d", old.SchemaVersion, new.SchemaVersion))
+	}
+
+	keys := make(map[string]bool)
+	for k := range old.ResolvedDependencies {
+		keys[k] = true
+	}
+	for k := range new.ResolvedDependencies {
+		keys[k] = true
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	for _, k := range sorted {
+		o, inOld := old.ResolvedDependencies[k]
+		n, inNew := new.ResolvedDependencies[k]
+
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s: %s", k, n.describe()))
+		case !inNew:
+			diff = append(diff, fmt.Sprintf("- %s: %s", k, o.describe()))
+		default:
+			if o.DependencyType != n.DependencyType {
+				diff = append(diff, fmt.Sprintf("~ %s: type %s -> %s", k, o.DependencyType, n.DependencyType))
+			}
+			if o.Uri != n.Uri {
+				diff = append(diff, fmt.Sprintf("~ %s: uri %s -> %s", k, o.Uri, n.Uri))
+			}
+			if o.Path != n.Path {
+				diff = append(diff, fmt.Sprintf("~ %s: path %s -> %s", k, o.Path, n.Path))
+			}
+			if o.Checksums["sha256"] != n.Checksums["sha256"] {
+				diff = append(diff, fmt.Sprintf("~ %s: sha256 %s -> %s", k, o.Checksums["sha256"], n.Checksums["sha256"]))
+			}
+		}
+	}
+
+	return diff
+}
+
+func (d *ResolvedDependency) describe() string {
+	if d.DependencyType == "local" {
+		return fmt.Sprintf("%s (%s)", d.Uri, d.Path)
+	}
+	return d.Uri
+}
+
+// PklRewriteDependencyUri replaces a quoted dependency uri in PklProject source,
+// formatting and comments of the file are kept as is
+func PklRewriteDependencyUri(source string, oldUri string, newUri string) (string, bool) {
+	pattern := regexp.MustCompile(`(uri\s*=\s*")` + regexp.QuoteMeta(oldUri) + `"`)
+
+	if !pattern.MatchString(source) {
+		return source, false
+	}
+
+	return pattern.ReplaceAllStringFunc(source, func(match string) string {
+		return strings.Replace(match, oldUri, newUri, 1)
+	}), true
+}
+
 func PklGetRelativePath(cacheDir string, baseUri *url.URL) string {
 	return filepath.Join(
 		cacheDir,
@@ -60,3 +148,22 @@ func PklUriToRef(uri string) (string, error) {
 	s := strings.Split(u.Path, "@")
 	return fmt.Sprintf("%s%s:%s", u.Host, s[0], s[1]), nil
 }
+
+// PklSplitVersion splits package uri into the versionless base uri and the version part,
+// e.g. package://host/path@^1.2 -> package://host/path, ^1.2
+func PklSplitVersion(uri string) (string, string) {
+	i := strings.LastIndex(uri, "@")
+	if i < 0 {
+		return uri, ""
+	}
+	return uri[:i], uri[i+1:]
+}
+
+// PklUriToRepository converts versionless package uri to the oci repository reference
+func PklUriToRepository(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s", u.Host, u.Path), nil
+}
@@ -0,0 +1,85 @@
+package pklutils
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiffDeps(t *testing.T) {
+	old := &ProjectDeps{
+		SchemaVersion: 1,
+		ResolvedDependencies: map[string]*ResolvedDependency{
+			"package://host/a@1": {DependencyType: "remote", Uri: "projectpackage://host/a@1.0.0", Checksums: map[string]string{"sha256": "aaa"}},
+			"package://host/b@1": {DependencyType: "remote", Uri: "projectpackage://host/b@1.0.0", Checksums: map[string]string{"sha256": "bbb"}},
+			"package://host/c@1": {DependencyType: "remote", Uri: "projectpackage://host/c@1.0.0", Checksums: map[string]string{"sha256": "ccc"}},
+		},
+	}
+
+	new := &ProjectDeps{
+		SchemaVersion: 1,
+		ResolvedDependencies: map[string]*ResolvedDependency{
+			"package://host/a@1": {DependencyType: "remote", Uri: "projectpackage://host/a@1.0.1", Checksums: map[string]string{"sha256": "aab"}},
+			"package://host/b@1": {DependencyType: "remote", Uri: "projectpackage://host/b@1.0.0", Checksums: map[string]string{"sha256": "bbb"}},
+			"package://host/d@1": {DependencyType: "local", Uri: "projectpackage://host/d@1.0.0", Path: "../d"},
+		},
+	}
+
+	expected := []string{
+		"~ package://host/a@1: uri projectpackage://host/a@1.0.0 -> projectpackage://host/a@1.0.1",
+		"~ package://host/a@1: sha256 aaa -> aab",
+		"- package://host/c@1: projectpackage://host/c@1.0.0",
+		"+ package://host/d@1: projectpackage://host/d@1.0.0 (../d)",
+	}
+
+	if diff := cmp.Diff(expected, DiffDeps(old, new)); diff != "" {
+		t.Errorf(diff)
+	}
+
+	if diff := DiffDeps(old, old); len(diff) > 0 {
+		t.Errorf("expected no difference, got %v", diff)
+	}
+
+	stale := &ProjectDeps{SchemaVersion: 0, ResolvedDependencies: old.ResolvedDependencies}
+	if diff := cmp.Diff([]string{"~ schemaVersion 0 -> 1"}, DiffDeps(stale, old)); diff != "" {
+		t.Errorf(diff)
+	}
+}
+
+func TestPklRewriteDependencyUri(t *testing.T) {
+	source := `amends "pkl:Project"
+
+dependencies {
+  // kubernetes templates
+  ["k8s"] {
+    uri   =  "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.1" // pinned
+  }
+  ["k8s-other"] { uri = "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.10" }
+}
+`
+
+	expected := `amends "pkl:Project"
+
+dependencies {
+  // kubernetes templates
+  ["k8s"] {
+    uri   =  "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.2.0" // pinned
+  }
+  ["k8s-other"] { uri = "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.10" }
+}
+`
+
+	actual, ok := PklRewriteDependencyUri(source, "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.0.1", "package://pkg.pkl-lang.org/pkl-k8s/k8s@1.2.0")
+
+	if !ok {
+		t.Fatal("uri not found")
+	}
+
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf(diff)
+	}
+
+	if _, ok := PklRewriteDependencyUri(source, "package://host/missing@1.0.0", "package://host/missing@1.1.0"); ok {
+		t.Errorf("expected missing uri not to be rewritten")
+	}
+}
@@ -2,6 +2,7 @@ package pklutils
 
 import (
 	"context"
+	"encoding/json"
 	"net/url"
 	"os"
 	"path/filepath"
@@ -48,3 +49,22 @@ func LoadProjectFromEvaluator(context context.Context, ev pkl.Evaluator, path st
 	}
 	return &proj, nil
 }
+
+// LoadConfig evaluates pkl configuration module as json and decodes it into out,
+// so the configuration can be written with plain untyped objects
+func LoadConfig(ctx context.Context, path string, out any) error {
+	ev, err := pkl.NewEvaluator(ctx, pkl.PreconfiguredOptions, func(opts *pkl.EvaluatorOptions) {
+		opts.OutputFormat = "json"
+	})
+	if err != nil {
+		return err
+	}
+	defer ev.Close()
+
+	text, err := ev.EvaluateOutputText(ctx, FileSource(path))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(text), out)
+}
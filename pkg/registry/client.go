@@ -14,6 +14,7 @@ import (
 	"github.com/apple/pkl-go/pkl"
 	"github.com/pkg/errors"
 
+	"github.com/opencontainers/go-digest"
 	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
 
 	"github.com/Masterminds/semver/v3"
@@ -215,13 +216,13 @@ type (
 )
 
 // Login logs into a registry
-func (c *Client) Login(host string, options ...LoginOption) error {
+func (c *Client) Login(parent context.Context, host string, options ...LoginOption) error {
 	operation := &loginOperation{}
 	for _, option := range options {
 		option(operation)
 	}
 	authorizerLoginOpts := []auth.LoginOption{
-		auth.WithLoginContext(ctx(c.out, c.debug)),
+		auth.WithLoginContext(ctx(parent, c.out, c.debug)),
 		auth.WithLoginHostname(host),
 		auth.WithLoginUsername(operation.username),
 		auth.WithLoginSecret(operation.password),
@@ -271,12 +272,12 @@ type (
 )
 
 // Logout logs out of a registry
-func (c *Client) Logout(host string, opts ...LogoutOption) error {
+func (c *Client) Logout(parent context.Context, host string, opts ...LogoutOption) error {
 	operation := &logoutOperation{}
 	for _, opt := range opts {
 		opt(operation)
 	}
-	if err := c.authorizer.Logout(ctx(c.out, c.debug), host); err != nil {
+	if err := c.authorizer.Logout(ctx(parent, c.out, c.debug), host); err != nil {
 		return err
 	}
 	fmt.Fprintf(c.out, "Removing login credentials for %s\n", host)
@@ -305,6 +306,8 @@ type (
 	DescriptorPullSummaryWithProject struct {
 		DescriptorPullSummary
 		Project *pkl.Project `json:"project"`
+		// Reader streams the package layer when pulled with the package, the caller must close it
+		Reader io.ReadCloser `json:"-"`
 	}
 
 	pullOperation struct {
@@ -313,7 +316,7 @@ type (
 )
 
 // Pull downloads a package from a registry
-func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
+func (c *Client) Pull(parent context.Context, ref string, options ...PullOption) (*PullResult, error) {
 	parsedRef, err := parseReference(ref)
 	if err != nil {
 		return nil, err
@@ -326,15 +329,12 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 		option(operation)
 	}
 
+	// the package layer is not copied into memory, it is streamed from the registry below
 	memoryStore := content.NewMemory()
 	allowedMediaTypes := []string{
 		ConfigMediaType, MetadataMediaType,
 	}
 	minNumDescriptors := 1 // 1 for the config
-	if operation.withPackage {
-		minNumDescriptors++
-		allowedMediaTypes = append(allowedMediaTypes, PackageLayerMediaType)
-	}
 
 	var descriptors, layers []ocispec.Descriptor
 	remotesResolver, err := c.resolver(parsedRef)
@@ -343,7 +343,7 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	}
 	registryStore := content.Registry{Resolver: remotesResolver}
 
-	manifest, err := oras.Copy(ctx(c.out, c.debug), registryStore, parsedRef.String(), memoryStore, "",
+	manifest, err := oras.Copy(ctx(parent, c.out, c.debug), registryStore, parsedRef.String(), memoryStore, "",
 		oras.WithPullEmptyNameAllowed(),
 		oras.WithAllowedMediaTypes(allowedMediaTypes),
 		oras.WithLayerDescriptors(func(l []ocispec.Descriptor) {
@@ -356,6 +356,23 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	descriptors = append(descriptors, manifest)
 	descriptors = append(descriptors, layers...)
 
+	if operation.withPackage {
+		minNumDescriptors++
+		_, manifestData, ok := memoryStore.Get(manifest)
+		if !ok {
+			return nil, errors.Errorf("Unable to retrieve blob with digest %s", manifest.Digest)
+		}
+		var ociManifest ocispec.Manifest
+		if err := json.Unmarshal(manifestData, &ociManifest); err != nil {
+			return nil, err
+		}
+		for _, layer := range ociManifest.Layers {
+			if layer.MediaType == PackageLayerMediaType {
+				descriptors = append(descriptors, layer)
+			}
+		}
+	}
+
 	numDescriptors := len(descriptors)
 	if numDescriptors < minNumDescriptors {
 		return nil, fmt.Errorf("manifest does not contain minimum number of descriptors (%d), descriptors found: %d",
@@ -441,17 +458,17 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	}
 
 	if operation.withPackage {
-		var getPackageDescriptorErr error
-		if _, pkgData, ok := memoryStore.Get(*pkgDescriptor); !ok {
-			getPackageDescriptorErr = errors.Errorf("Unable to retrieve blob with digest %s", pkgDescriptor.Digest)
-		} else {
-			result.Archive.Data = pkgData
-			result.Archive.Digest = pkgDescriptor.Digest.String()
-			result.Archive.Size = pkgDescriptor.Size
+		fetcher, err := remotesResolver.Fetcher(ctx(parent, c.out, c.debug), parsedRef.String())
+		if err != nil {
+			return nil, err
 		}
-		if getPackageDescriptorErr != nil {
-			return nil, getPackageDescriptorErr
+		reader, err := fetcher.Fetch(ctx(parent, c.out, c.debug), *pkgDescriptor)
+		if err != nil {
+			return nil, err
 		}
+		result.Archive.Reader = reader
+		result.Archive.Digest = pkgDescriptor.Digest.String()
+		result.Archive.Size = pkgDescriptor.Size
 	}
 
 	fmt.Fprintf(c.out, "Pulled: %s\n", result.Ref)
@@ -502,7 +519,7 @@ type (
 )
 
 // Push uploads a package to a registry.
-func (c *Client) Push(archiveFile string, metadataFile string, ref string, project *pkl.Project, options ...PushOption) (*PushResult, error) {
+func (c *Client) Push(parent context.Context, archiveFile string, metadataFile string, ref string, project *pkl.Project, options ...PushOption) (*PushResult, error) {
 	parsedRef, err := parseReference(ref)
 	if err != nil {
 		return nil, err
@@ -524,24 +541,23 @@ func (c *Client) Push(archiveFile string, metadataFile string, ref string, proje
 				"strict mode enabled, ref basename and tag must match the package name and version")
 		}
 	}
-	memoryStore := content.NewMemory()
+	// the archive is streamed from disk, only the small metadata and config blobs are kept in memory
+	fileStore := content.NewFile(filepath.Dir(archiveFile))
+	defer fileStore.Close()
 
-	data, err := os.ReadFile(archiveFile)
+	pkgDescriptor, err := fileStore.Add(filepath.Base(archiveFile), PackageLayerMediaType, archiveFile)
 	if err != nil {
 		return nil, err
 	}
+	// the file store looks blobs up by digest, drop the file name so the manifest does not depend on local paths
+	pkgDescriptor.Annotations = nil
 
 	metadata, err := os.ReadFile(metadataFile)
 	if err != nil {
 		return nil, err
 	}
 
-	pkgDescriptor, err := memoryStore.Add("", PackageLayerMediaType, data)
-	if err != nil {
-		return nil, err
-	}
-
-	metadataDescriptor, err := memoryStore.Add("", MetadataMediaType, metadata)
+	metadataDescriptor, err := loadBlob(fileStore, MetadataMediaType, metadata)
 	if err != nil {
 		return nil, err
 	}
@@ -551,7 +567,7 @@ func (c *Client) Push(archiveFile string, metadataFile string, ref string, proje
 		return nil, err
 	}
 
-	configDescriptor, err := memoryStore.Add("", ConfigMediaType, configData)
+	configDescriptor, err := loadBlob(fileStore, ConfigMediaType, configData)
 	if err != nil {
 		return nil, err
 	}
@@ -565,7 +581,7 @@ func (c *Client) Push(archiveFile string, metadataFile string, ref string, proje
 		return nil, err
 	}
 
-	if err := memoryStore.StoreManifest(parsedRef.String(), manifest, manifestData); err != nil {
+	if err := fileStore.StoreManifest(parsedRef.String(), manifest, manifestData); err != nil {
 		return nil, err
 	}
 
@@ -574,7 +590,7 @@ func (c *Client) Push(archiveFile string, metadataFile string, ref string, proje
 		return nil, err
 	}
 	registryStore := content.Registry{Resolver: remotesResolver}
-	_, err = oras.Copy(ctx(c.out, c.debug), memoryStore, parsedRef.String(), registryStore, "",
+	_, err = oras.Copy(ctx(parent, c.out, c.debug), fileStore, parsedRef.String(), registryStore, "",
 		oras.WithNameValidation(nil))
 	if err != nil {
 		return nil, err
@@ -611,6 +627,16 @@ func (c *Client) Push(archiveFile string, metadataFile string, ref string, proje
 	return result, err
 }
 
+// loadBlob keeps a small blob in memory of the file store
+func loadBlob(store *content.File, mediaType string, data []byte) (ocispec.Descriptor, error) {
+	desc := ocispec.Descriptor{
+		MediaType: mediaType,
+		Digest:    digest.FromBytes(data),
+		Size:      int64(len(data)),
+	}
+	return desc, store.Load(desc, data)
+}
+
 // PushOptStrictMode returns a function that sets the strictMode setting on push
 func PushOptStrictMode(strictMode bool) PushOption {
 	return func(operation *pushOperation) {
@@ -626,7 +652,7 @@ func PushOptCreationTime(creationTime string) PushOption {
 }
 
 // Tags provides a sorted list all semver compliant tags for a given repository
-func (c *Client) Tags(ref string) ([]string, error) {
+func (c *Client) Tags(parent context.Context, ref string) ([]string, error) {
 	parsedReference, err := registry.ParseReference(ref)
 	if err != nil {
 		return nil, err
@@ -640,7 +666,7 @@ func (c *Client) Tags(ref string) ([]string, error) {
 
 	var registryTags []string
 
-	registryTags, err = registry.Tags(ctx(c.out, c.debug), &repository)
+	registryTags, err = registry.Tags(ctx(parent, c.out, c.debug), &repository)
 	if err != nil {
 		return nil, err
 	}
@@ -667,3 +693,23 @@ func (c *Client) Tags(ref string) ([]string, error) {
 	return tags, nil
 
 }
+
+// Resolve returns the manifest digest of a reference without pulling its content
+func (c *Client) Resolve(parent context.Context, ref string) (string, error) {
+	parsedRef, err := parseReference(ref)
+	if err != nil {
+		return "", err
+	}
+
+	remotesResolver, err := c.resolver(parsedRef)
+	if err != nil {
+		return "", err
+	}
+
+	_, desc, err := remotesResolver.Resolve(ctx(parent, c.out, c.debug), parsedRef.String())
+	if err != nil {
+		return "", err
+	}
+
+	return desc.Digest.String(), nil
+}
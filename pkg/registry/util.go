@@ -18,13 +18,13 @@ var immutableOciAnnotations = []string{
 	ocispec.AnnotationTitle,
 }
 
-// ctx retrieves a fresh context.
+// ctx derives a context from the caller's one.
 // disable verbose logging coming from ORAS (unless debug is enabled)
-func ctx(out io.Writer, debug bool) context.Context {
+func ctx(parent context.Context, out io.Writer, debug bool) context.Context {
 	if !debug {
-		return orascontext.Background()
+		return orascontext.WithLoggerDiscarded(parent)
 	}
-	ctx := orascontext.WithLoggerFromWriter(context.Background(), out)
+	ctx := orascontext.WithLoggerFromWriter(parent, out)
 	orascontext.GetLogger(ctx).Logger.SetLevel(logrus.DebugLevel)
 	return ctx
 }